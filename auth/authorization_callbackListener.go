@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 )
 
 // HTTPServer abstracts the functions needed for starting and shutting down an
@@ -71,26 +72,40 @@ func (c *CallbackService) GetCallbackURL() string {
 // authorization code callback
 func (c *CallbackService) BuildCodeResponseHandler(responseC chan CallbackResponse, state string) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		response := CallbackResponse{}
+		response := callbackResponseFromQuery(r.URL.Query(), state)
 
-		if r.URL.Query().Get("state") != state {
-			response.Error = errors.New("callback completed with incorrect state")
+		if response.Error != nil {
 			w.Write([]byte("An error occurred. Please check terminal for output."))
-		} else if callbackErr := r.URL.Query().Get("error"); callbackErr != "" {
-			response.Error = fmt.Errorf("%s: %s", callbackErr, r.URL.Query().Get("error_description"))
-			w.Write([]byte("An error occurred. Please check terminal for output."))
-		} else if code := r.URL.Query().Get("code"); code != "" {
-			response.Code = code
-			w.Write([]byte("You've been authorized and may now close this browser page."))
 		} else {
-			response.Error = errors.New("callback completed with no error or code")
-			w.Write([]byte("An error occurred. Please check terminal for output."))
+			w.Write([]byte("You've been authorized and may now close this browser page."))
 		}
 
 		responseC <- response
 	}
 }
 
+// callbackResponseFromQuery validates the state of the authorization code
+// callback query and builds the CallbackResponse from it
+func callbackResponseFromQuery(query url.Values, state string) CallbackResponse {
+	response := CallbackResponse{}
+
+	if query.Get("state") != state {
+		response.Error = errors.New("callback completed with incorrect state")
+	} else if callbackErr := query.Get("error"); callbackErr != "" {
+		response.Error = &OAuthError{
+			Code:        callbackErr,
+			Description: query.Get("error_description"),
+			URI:         query.Get("error_uri"),
+		}
+	} else if code := query.Get("code"); code != "" {
+		response.Code = code
+	} else {
+		response.Error = errors.New("callback completed with no error or code")
+	}
+
+	return response
+}
+
 // Close tells the HTTP server to gracefully shutdown
 func (c *CallbackService) Close() {
 	c.httpServer.Shutdown()
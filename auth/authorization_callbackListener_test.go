@@ -73,7 +73,7 @@ var _ = Describe("AuthCallbackService", func() {
 
 		go server.BuildCodeResponseHandler(resp, "noonce")(mockHTTP.httpRecorder, req)
 
-		Expect(<-resp).To(Equal(CallbackResponse{Error: errors.New("uh_oh: something went wrong")}))
+		Expect(<-resp).To(Equal(CallbackResponse{Error: &OAuthError{Code: "uh_oh", Description: "something went wrong"}}))
 	})
 
 	It("returns an error if no error or code is in the callback url", func() {
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 // LocalCodeProvider holds the information needed to easily get an
@@ -14,6 +16,9 @@ type LocalCodeProvider struct {
 	listener               AuthorizationCallbackListener
 	osInteractor           OSInteractor
 	state                  State
+	nonce                  Nonce
+	pusher                 AuthorizationRequestPusher
+	requirePAR             bool
 }
 
 // AuthorizationCodeResult holds the needed code and redirect URI needed to exchange a
@@ -21,6 +26,9 @@ type LocalCodeProvider struct {
 type AuthorizationCodeResult struct {
 	Code        string
 	RedirectURI string
+	// Nonce is the nonce that was sent in the authorization request and
+	// that the ID token must contain
+	Nonce string
 }
 
 // CallbackResponse holds the code gotten from the authorization callback.
@@ -37,24 +45,40 @@ type AuthorizationCallbackListener interface {
 	Close()
 }
 
+// AuthorizationRequestPusher abstracts pushing the authorization request
+// parameters to the issuer ahead of opening the authorization endpoint
+type AuthorizationRequestPusher interface {
+	PushAuthorizationRequest(params url.Values) (*PushedAuthorizationResponse, error)
+}
+
 // OSInteractor abstracts opening a url on the users OS
 type OSInteractor interface {
 	OpenURL(url string) error
 }
 
-// NewLocalCodeProvider allows for the easy setup of LocalCodeProvider
+// NewLocalCodeProvider allows for the easy setup of LocalCodeProvider.
+// Authorization requests are pushed using <pusher> when the issuer advertises
+// a pushed authorization request endpoint. <pusher> can be nil to never push
+// authorization requests. When <requirePAR> is true an error is returned
+// instead of falling back to sending the parameters in the browser URL.
 func NewLocalCodeProvider(
 	issuer Issuer,
 	oidcWellKnownEndpoints OIDCWellKnownEndpoints,
 	callbackListener AuthorizationCallbackListener,
 	osInteractor OSInteractor,
-	state State) *LocalCodeProvider {
+	state State,
+	nonce Nonce,
+	pusher AuthorizationRequestPusher,
+	requirePAR bool) *LocalCodeProvider {
 	return &LocalCodeProvider{
 		issuer,
 		oidcWellKnownEndpoints,
 		callbackListener,
 		osInteractor,
 		state,
+		nonce,
+		pusher,
+		requirePAR,
 	}
 }
 
@@ -66,7 +90,7 @@ func (cp *LocalCodeProvider) GetCode(challenge Challenge, additionalScopes ...st
 	codeReceiverCh := make(chan CallbackResponse)
 	defer close(codeReceiverCh)
 	state := cp.state()
-	go cp.listener.AwaitResponse(codeReceiverCh, state)
+	nonce := cp.nonce()
 
 	params := url.Values{
 		"audience":              []string{cp.Audience},
@@ -77,12 +101,19 @@ func (cp *LocalCodeProvider) GetCode(challenge Challenge, additionalScopes ...st
 		"response_type":         []string{"code"},
 		"scope":                 []string{strings.Join(append([]string{"openid", "email"}, additionalScopes...), " ")},
 		"state":                 []string{state},
+		"nonce":                 []string{nonce},
 	}
 
-	if err := cp.osInteractor.OpenURL(fmt.Sprintf("%s?%s",
-		cp.oidcWellKnownEndpoints.AuthorizationEndpoint,
-		params.Encode(),
-	)); err != nil {
+	// the URL is built first as pushing it can fail, which would otherwise
+	// leave the listener waiting for a callback that never comes
+	authorizationURL, err := cp.buildAuthorizationURL(params)
+	if err != nil {
+		return nil, err
+	}
+
+	go cp.listener.AwaitResponse(codeReceiverCh, state)
+
+	if err := cp.osInteractor.OpenURL(authorizationURL); err != nil {
 		return nil, err
 	}
 
@@ -96,5 +127,30 @@ func (cp *LocalCodeProvider) GetCode(challenge Challenge, additionalScopes ...st
 	return &AuthorizationCodeResult{
 		Code:        callbackResult.Code,
 		RedirectURI: cp.listener.GetCallbackURL(),
+		Nonce:       nonce,
 	}, nil
 }
+
+// buildAuthorizationURL builds the URL of the authorization endpoint that is
+// opened in the browser. When the authorization request is pushed only the
+// client_id and request_uri are sent in the URL.
+func (cp *LocalCodeProvider) buildAuthorizationURL(params url.Values) (string, error) {
+	canPush := cp.pusher != nil && cp.oidcWellKnownEndpoints.PushedAuthorizationRequestEndpoint != ""
+	if !canPush {
+		if cp.requirePAR || cp.oidcWellKnownEndpoints.RequirePushedAuthorizationRequests {
+			return "", errors.New("pushed authorization requests are required but the issuer does not advertise a pushed_authorization_request_endpoint")
+		}
+
+		return fmt.Sprintf("%s?%s", cp.oidcWellKnownEndpoints.AuthorizationEndpoint, params.Encode()), nil
+	}
+
+	par, err := cp.pusher.PushAuthorizationRequest(params)
+	if err != nil {
+		return "", errors.Wrap(err, "could not push authorization request")
+	}
+
+	return fmt.Sprintf("%s?%s", cp.oidcWellKnownEndpoints.AuthorizationEndpoint, url.Values{
+		"client_id":   []string{cp.ClientID},
+		"request_uri": []string{par.RequestURI},
+	}.Encode()), nil
+}
@@ -59,6 +59,17 @@ func (i *mockInteractor) OpenURL(url string) error {
 	return i.ReturnsError
 }
 
+type mockAuthorizationRequestPusher struct {
+	CalledWith    url.Values
+	ReturnsResult *PushedAuthorizationResponse
+	ReturnsError  error
+}
+
+func (p *mockAuthorizationRequestPusher) PushAuthorizationRequest(params url.Values) (*PushedAuthorizationResponse, error) {
+	p.CalledWith = params
+	return p.ReturnsResult, p.ReturnsError
+}
+
 var _ = Describe("AuthCodeProvider", func() {
 	issuerData := Issuer{
 		IssuerEndpoint: "https://issuer",
@@ -75,6 +86,9 @@ var _ = Describe("AuthCodeProvider", func() {
 	stateResult := "randomstring1234"
 	mockState := func() string { return stateResult }
 
+	nonceResult := "randomnonce1234"
+	mockNonce := func() string { return nonceResult }
+
 	It("waits for a response from the callback", func(done Done) {
 		mockListener := newMockCallbackListener()
 		provider := NewLocalCodeProvider(
@@ -83,6 +97,9 @@ var _ = Describe("AuthCodeProvider", func() {
 			mockListener,
 			&mockInteractor{},
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 		go provider.GetCode(challenge)
 
@@ -100,6 +117,9 @@ var _ = Describe("AuthCodeProvider", func() {
 			mockListener,
 			&mockInteractor{},
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 		go mockListener.CompleteCallback(CallbackResponse{})
 
@@ -117,6 +137,9 @@ var _ = Describe("AuthCodeProvider", func() {
 			mockListener,
 			mockOSInteractor,
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 
 		go mockListener.CompleteCallback(CallbackResponse{})
@@ -130,8 +153,9 @@ var _ = Describe("AuthCodeProvider", func() {
 		Expect(parsedURL.Host).To(Equal("issuer"))
 
 		params := parsedURL.Query()
-		Expect(len(params)).To(Equal(8))
+		Expect(len(params)).To(Equal(9))
 		Expect(params.Get("state")).To(Equal(stateResult))
+		Expect(params.Get("nonce")).To(Equal(nonceResult))
 		Expect(params.Get("audience")).To(Equal(issuerData.Audience))
 		Expect(params.Get("scope")).To(Equal("openid email scope1 scope2 scope3"))
 		Expect(params.Get("response_type")).To(Equal("code"))
@@ -149,6 +173,9 @@ var _ = Describe("AuthCodeProvider", func() {
 			mockListener,
 			&mockInteractor{},
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 
 		go mockListener.CompleteCallback(CallbackResponse{Code: "mycode", Error: nil})
@@ -156,6 +183,7 @@ var _ = Describe("AuthCodeProvider", func() {
 		result, _ := provider.GetCode(challenge)
 		Expect(result.Code).To(Equal("mycode"))
 		Expect(result.RedirectURI).To(Equal(mockListener.GetCallbackURL()))
+		Expect(result.Nonce).To(Equal(nonceResult))
 
 	})
 
@@ -169,6 +197,9 @@ var _ = Describe("AuthCodeProvider", func() {
 				ReturnsError: errors.New("someerror"),
 			},
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 
 		_, err := provider.GetCode(challenge)
@@ -184,6 +215,9 @@ var _ = Describe("AuthCodeProvider", func() {
 			mockListener,
 			&mockInteractor{},
 			mockState,
+			mockNonce,
+			nil,
+			false,
 		)
 
 		go mockListener.CompleteCallback(CallbackResponse{
@@ -197,4 +231,121 @@ var _ = Describe("AuthCodeProvider", func() {
 		Expect(err).NotTo(BeNil())
 		Expect(err.Error()).To(Equal("someerror"))
 	})
+
+	Describe("pushed authorization requests", func() {
+		parEndpoints := OIDCWellKnownEndpoints{
+			AuthorizationEndpoint:              "https://issuer/the/authorize/endpoint",
+			PushedAuthorizationRequestEndpoint: "https://issuer/the/par/endpoint",
+		}
+
+		It("pushes the auth params and opens the URL with the request_uri", func() {
+			mockListener := newMockCallbackListener()
+			mockOSInteractor := &mockInteractor{}
+			mockPusher := &mockAuthorizationRequestPusher{
+				ReturnsResult: &PushedAuthorizationResponse{RequestURI: "urn:ietf:params:oauth:request_uri:abc"},
+			}
+			provider := NewLocalCodeProvider(
+				issuerData,
+				parEndpoints,
+				mockListener,
+				mockOSInteractor,
+				mockState,
+				mockNonce,
+				mockPusher,
+				false,
+			)
+
+			go mockListener.CompleteCallback(CallbackResponse{Code: "mycode"})
+
+			result, err := provider.GetCode(challenge, "scope1")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Code).To(Equal("mycode"))
+
+			Expect(mockPusher.CalledWith.Get("state")).To(Equal(stateResult))
+			Expect(mockPusher.CalledWith.Get("nonce")).To(Equal(nonceResult))
+			Expect(mockPusher.CalledWith.Get("code_challenge")).To(Equal(challenge.Code))
+			Expect(mockPusher.CalledWith.Get("scope")).To(Equal("openid email scope1"))
+
+			parsedURL, err := url.Parse(mockOSInteractor.URL)
+			Expect(err).To(BeNil())
+			Expect(parsedURL.Path).To(Equal("/the/authorize/endpoint"))
+			Expect(parsedURL.Query()).To(Equal(url.Values{
+				"client_id":   []string{issuerData.ClientID},
+				"request_uri": []string{"urn:ietf:params:oauth:request_uri:abc"},
+			}))
+		})
+
+		It("does not open the URL when pushing fails", func() {
+			mockOSInteractor := &mockInteractor{}
+			provider := NewLocalCodeProvider(
+				issuerData,
+				parEndpoints,
+				newMockCallbackListener(),
+				mockOSInteractor,
+				mockState,
+				mockNonce,
+				&mockAuthorizationRequestPusher{ReturnsError: errors.New("invalid_request: bad")},
+				false,
+			)
+
+			_, err := provider.GetCode(challenge)
+
+			Expect(err.Error()).To(Equal("could not push authorization request: invalid_request: bad"))
+			Expect(mockOSInteractor.URL).To(BeEmpty())
+		})
+
+		It("does not wait for the callback when pushing fails", func() {
+			mockListener := newMockCallbackListener()
+			provider := NewLocalCodeProvider(
+				issuerData,
+				parEndpoints,
+				mockListener,
+				&mockInteractor{},
+				mockState,
+				mockNonce,
+				&mockAuthorizationRequestPusher{ReturnsError: errors.New("invalid_request: bad")},
+				false,
+			)
+
+			_, err := provider.GetCode(challenge)
+
+			Expect(err).To(HaveOccurred())
+			Consistently(func() bool { return mockListener.AwaitCalled }, "50ms").Should(BeFalse())
+		})
+
+		It("errors when PAR is required but not advertised", func() {
+			provider := NewLocalCodeProvider(
+				issuerData,
+				OIDCWellKnownEndpoints{AuthorizationEndpoint: "https://issuer/the/authorize/endpoint"},
+				newMockCallbackListener(),
+				&mockInteractor{},
+				mockState,
+				mockNonce,
+				&mockAuthorizationRequestPusher{},
+				true,
+			)
+
+			_, err := provider.GetCode(challenge)
+
+			Expect(err.Error()).To(Equal("pushed authorization requests are required but the issuer does not advertise a pushed_authorization_request_endpoint"))
+		})
+
+		It("errors when the issuer requires PAR and no pusher is set", func() {
+			provider := NewLocalCodeProvider(
+				issuerData,
+				OIDCWellKnownEndpoints{RequirePushedAuthorizationRequests: true},
+				newMockCallbackListener(),
+				&mockInteractor{},
+				mockState,
+				mockNonce,
+				nil,
+				false,
+			)
+
+			_, err := provider.GetCode(challenge)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
 })
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultDevicePollingInterval is the polling interval RFC 8628 says to use
+// when the device authorization response does not include one
+const defaultDevicePollingInterval = 5 * time.Second
+
+// slowDownIntervalIncrease is how much the polling interval is increased by
+// each time the token endpoint responds with slow_down
+const slowDownIntervalIncrease = 5 * time.Second
+
+// DeviceCodeExchanger abstracts requesting a device code and exchanging it
+// for tokens
+type DeviceCodeExchanger interface {
+	RequestDeviceCode(req DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error)
+	ExchangeDeviceCode(req DeviceCodeExchangeRequest) (*TokenResult, error)
+}
+
+// DeviceCodeProvider holds the information needed to get tokens using the
+// device authorization grant. It is useful when a browser cannot be opened or
+// a local callback cannot be received.
+type DeviceCodeProvider struct {
+	Issuer
+	exchanger DeviceCodeExchanger
+	output    io.Writer
+	// sleep allows us to avoid waiting on the polling interval in tests
+	sleep func(time.Duration)
+}
+
+// NewDeviceCodeProvider allows for the easy setup of DeviceCodeProvider. The
+// verification URI and user code are written to <output>.
+func NewDeviceCodeProvider(issuer Issuer, exchanger DeviceCodeExchanger, output io.Writer) *DeviceCodeProvider {
+	return &DeviceCodeProvider{
+		Issuer:    issuer,
+		exchanger: exchanger,
+		output:    output,
+		sleep:     time.Sleep,
+	}
+}
+
+// GetTokens requests a device code, tells the user where to enter the user
+// code and then polls the token endpoint until the user has completed
+// authorization. Additional scopes beyond openid and email can be sent by
+// passing in arguments for <additionalScopes>.
+func (dp *DeviceCodeProvider) GetTokens(additionalScopes ...string) (*TokenResult, error) {
+	deviceAuth, err := dp.exchanger.RequestDeviceCode(DeviceAuthorizationRequest{
+		ClientID: dp.ClientID,
+		Audience: dp.Audience,
+		Scopes:   append([]string{"openid", "email"}, additionalScopes...),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not request device code")
+	}
+
+	fmt.Fprintf(dp.output, "To authenticate, visit %s and enter the code: %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	if deviceAuth.VerificationURIComplete != "" {
+		fmt.Fprintf(dp.output, "Alternatively, visit %s\n", deviceAuth.VerificationURIComplete)
+	}
+
+	interval := time.Duration(deviceAuth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDevicePollingInterval
+	}
+	expiresIn := time.Duration(deviceAuth.ExpiresIn) * time.Second
+
+	exchangeRequest := DeviceCodeExchangeRequest{
+		ClientID:   dp.ClientID,
+		DeviceCode: deviceAuth.DeviceCode,
+	}
+
+	var waited time.Duration
+	for {
+		if expiresIn > 0 && waited >= expiresIn {
+			return nil, errors.New("device code expired before authorization was completed")
+		}
+
+		dp.sleep(interval)
+		waited += interval
+
+		tokenResult, err := dp.exchanger.ExchangeDeviceCode(exchangeRequest)
+		switch err {
+		case nil:
+			return tokenResult, nil
+		case ErrAuthorizationPending:
+		case ErrSlowDown:
+			interval += slowDownIntervalIncrease
+		default:
+			return nil, errors.Wrap(err, "could not exchange device code")
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockDeviceCodeExchanger struct {
+	RequestCalledWith  *DeviceAuthorizationRequest
+	ReturnsDeviceAuth  *DeviceAuthorizationResponse
+	RequestReturnsErr  error
+	ExchangeCalledWith []DeviceCodeExchangeRequest
+	ExchangeReturns    []error
+	ReturnsTokens      *TokenResult
+}
+
+func (m *mockDeviceCodeExchanger) RequestDeviceCode(req DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error) {
+	m.RequestCalledWith = &req
+	return m.ReturnsDeviceAuth, m.RequestReturnsErr
+}
+
+func (m *mockDeviceCodeExchanger) ExchangeDeviceCode(req DeviceCodeExchangeRequest) (*TokenResult, error) {
+	m.ExchangeCalledWith = append(m.ExchangeCalledWith, req)
+	if len(m.ExchangeReturns) > 0 {
+		err := m.ExchangeReturns[0]
+		m.ExchangeReturns = m.ExchangeReturns[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m.ReturnsTokens, nil
+}
+
+var _ = Describe("DeviceCodeProvider", func() {
+	issuerData := Issuer{
+		IssuerEndpoint: "https://issuer",
+		ClientID:       "test_clientID",
+		Audience:       "test_audience",
+	}
+
+	var exchanger *mockDeviceCodeExchanger
+	var output *bytes.Buffer
+	var slept []time.Duration
+	var provider *DeviceCodeProvider
+
+	BeforeEach(func() {
+		exchanger = &mockDeviceCodeExchanger{
+			ReturnsDeviceAuth: &DeviceAuthorizationResponse{
+				DeviceCode:      "device-code",
+				UserCode:        "ABCD-EFGH",
+				VerificationURI: "https://issuer/activate",
+				ExpiresIn:       600,
+				Interval:        2,
+			},
+			ReturnsTokens: &TokenResult{AccessToken: "access"},
+		}
+		output = &bytes.Buffer{}
+		slept = nil
+		provider = NewDeviceCodeProvider(issuerData, exchanger, output)
+		provider.sleep = func(d time.Duration) { slept = append(slept, d) }
+	})
+
+	It("requests a device code with the issuer data and scopes", func() {
+		provider.GetTokens("offline_access")
+
+		Expect(exchanger.RequestCalledWith).To(Equal(&DeviceAuthorizationRequest{
+			ClientID: "test_clientID",
+			Audience: "test_audience",
+			Scopes:   []string{"openid", "email", "offline_access"},
+		}))
+	})
+
+	It("prints the verification uri and user code", func() {
+		exchanger.ReturnsDeviceAuth.VerificationURIComplete = "https://issuer/activate?user_code=ABCD-EFGH"
+
+		provider.GetTokens()
+
+		Expect(output.String()).To(Equal("To authenticate, visit https://issuer/activate and enter the code: ABCD-EFGH\n" +
+			"Alternatively, visit https://issuer/activate?user_code=ABCD-EFGH\n"))
+	})
+
+	It("polls until authorization is no longer pending", func() {
+		exchanger.ExchangeReturns = []error{ErrAuthorizationPending, ErrAuthorizationPending, nil}
+
+		tokens, err := provider.GetTokens()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens).To(Equal(exchanger.ReturnsTokens))
+		Expect(exchanger.ExchangeCalledWith).To(HaveLen(3))
+		Expect(exchanger.ExchangeCalledWith[0]).To(Equal(DeviceCodeExchangeRequest{
+			ClientID:   "test_clientID",
+			DeviceCode: "device-code",
+		}))
+		Expect(slept).To(Equal([]time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second}))
+	})
+
+	It("increases the interval when asked to slow down", func() {
+		exchanger.ExchangeReturns = []error{ErrSlowDown, ErrAuthorizationPending, nil}
+
+		_, err := provider.GetTokens()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(slept).To(Equal([]time.Duration{2 * time.Second, 7 * time.Second, 7 * time.Second}))
+	})
+
+	It("uses the default interval when none is sent", func() {
+		exchanger.ReturnsDeviceAuth.Interval = 0
+
+		provider.GetTokens()
+
+		Expect(slept).To(Equal([]time.Duration{5 * time.Second}))
+	})
+
+	It("errors when the device code expires", func() {
+		exchanger.ReturnsDeviceAuth.ExpiresIn = 4
+		exchanger.ExchangeReturns = []error{ErrAuthorizationPending, ErrAuthorizationPending, nil}
+
+		tokens, err := provider.GetTokens()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("device code expired before authorization was completed"))
+		Expect(exchanger.ExchangeCalledWith).To(HaveLen(2))
+	})
+
+	It("returns an error when requesting the device code errors", func() {
+		exchanger.RequestReturnsErr = errors.New("someerror")
+
+		tokens, err := provider.GetTokens()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not request device code: someerror"))
+	})
+
+	It("returns an error when the exchange fails", func() {
+		exchanger.ExchangeReturns = []error{errors.New("access_denied: the user said no")}
+
+		tokens, err := provider.GetTokens()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not exchange device code: access_denied: the user said no"))
+	})
+})
@@ -1,12 +1,16 @@
 package auth
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // TokenRetriever implements AuthTokenExchanger in order to facilitate getting
@@ -14,17 +18,27 @@ import (
 type TokenRetriever struct {
 	oidcWellKnownEndpoints OIDCWellKnownEndpoints
 	transport              HTTPAuthTransport
+	clientAuthenticator    ClientAuthenticator
+	// dpopProver adds DPoP proofs to requests when it is set
+	dpopProver *DPoPProver
+	// idTokenVerifier verifies the ID tokens the user logs in with when it
+	// is set
+	idTokenVerifier *IDTokenVerifier
 }
 
 // AuthorizationTokenResponse is the HTTP response when asking for a new token.
 // Note that not all fields will contain data based on what kind of request was
 // sent
 type AuthorizationTokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	ExpiresIn    int    `json:"expires_in"`
-	IDToken      string `json:"id_token"`
-	RefreshToken string `json:"refresh_token"`
-	TokenType    string `json:"token_type"`
+	AccessToken     string `json:"access_token"`
+	ExpiresIn       int    `json:"expires_in"`
+	IDToken         string `json:"id_token"`
+	RefreshToken    string `json:"refresh_token"`
+	TokenType       string `json:"token_type"`
+	IssuedTokenType string `json:"issued_token_type"`
+	// clockSkew is how many seconds the issuer's clock is ahead of the local
+	// clock according to the Date header of the response
+	clockSkew int64
 }
 
 // AuthorizationCodeExchangeRequest is used to request the exchange of an
@@ -43,22 +57,119 @@ type RefreshTokenExchangeRequest struct {
 	RefreshToken string
 }
 
+// ClientCredentialsExchangeRequest is used to request tokens for a client
+// using its own credentials
+type ClientCredentialsExchangeRequest struct {
+	ClientID string
+	Audience string
+}
+
+// JWTBearerExchangeRequest is used to request tokens using a signed JWT
+// assertion
+type JWTBearerExchangeRequest struct {
+	ClientID  string
+	Assertion string
+	Audience  string
+}
+
+// Token type identifiers defined by RFC 8693 for use in token exchange
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// TokenExchangeRequest is used to request the exchange of a token for a new
+// token with a different audience
+type TokenExchangeRequest struct {
+	ClientID           string
+	SubjectToken       string
+	SubjectTokenType   string
+	RequestedTokenType string
+	Audience           string
+}
+
+// DeviceAuthorizationRequest is used to request a device code and user code
+// from the device authorization endpoint
+type DeviceAuthorizationRequest struct {
+	ClientID string
+	Audience string
+	Scopes   []string
+}
+
+// DeviceAuthorizationResponse is the HTTP response when asking for a device
+// code
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceCodeExchangeRequest is used to request the exchange of a device code
+// for a token
+type DeviceCodeExchangeRequest struct {
+	ClientID   string
+	DeviceCode string
+}
+
+// TokenRevocationRequest is used to revoke a token at the revocation
+// endpoint
+type TokenRevocationRequest struct {
+	ClientID      string
+	Token         string
+	TokenTypeHint string
+}
+
+// PushedAuthorizationResponse is the HTTP response when pushing an
+// authorization request to the pushed authorization request endpoint
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// tokenErrorResponse is the HTTP response body sent by the token
+// endpoint when a request could not be fulfilled
+type tokenErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorURI         string `json:"error_uri"`
+}
+
+// ErrAuthorizationPending is returned when exchanging a device code before the
+// user has completed authorization
+var ErrAuthorizationPending = errors.New("authorization_pending")
+
+// ErrSlowDown is returned when exchanging a device code and the token endpoint
+// asks for the polling interval to be increased
+var ErrSlowDown = errors.New("slow_down")
+
+// ErrInvalidGrant matches the OAuthError returned when exchanging a refresh
+// token that is invalid, expired or revoked. Issuers that rotate refresh
+// tokens also return it when a refresh token that was already used is sent
+// again.
+var ErrInvalidGrant = errors.New("invalid_grant")
+
 // HTTPAuthTransport abstracts how an HTTP exchange request is sent and received
 type HTTPAuthTransport interface {
 	Do(request *http.Request) (*http.Response, error)
 }
 
 // NewTokenRetriever allows a TokenRetriever the internal of a new
-// TokenRetriever to be easily set up
-func NewTokenRetriever(oidcWellKnownEndpoints OIDCWellKnownEndpoints, authTransport HTTPAuthTransport) *TokenRetriever {
+// TokenRetriever to be easily set up. <clientAuthenticator> can be nil for
+// public clients.
+func NewTokenRetriever(oidcWellKnownEndpoints OIDCWellKnownEndpoints, authTransport HTTPAuthTransport, clientAuthenticator ClientAuthenticator) *TokenRetriever {
 	return &TokenRetriever{
 		oidcWellKnownEndpoints: oidcWellKnownEndpoints,
 		transport:              authTransport,
+		clientAuthenticator:    clientAuthenticator,
 	}
 }
 
 // newExchangeCodeRequest builds a new AuthTokenRequest wrapped in an
-// http.Request
+// http.Request. The request is never retried as the authorization code can
+// only be used once.
 func (ce *TokenRetriever) newExchangeCodeRequest(req AuthorizationCodeExchangeRequest) (*http.Request, error) {
 	uv := url.Values{}
 	uv.Set("grant_type", "authorization_code")
@@ -67,10 +178,44 @@ func (ce *TokenRetriever) newExchangeCodeRequest(req AuthorizationCodeExchangeRe
 	uv.Set("code", req.Code)
 	uv.Set("redirect_uri", req.RedirectURI)
 
+	request, err := ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+	if err != nil {
+		return nil, err
+	}
+
+	return withoutRetries(request), nil
+}
+
+// newAuthenticatedRequest builds a new form encoded POST http.Request for the
+// passed in endpoint and adds client authentication to it when the client is
+// confidential
+func (ce *TokenRetriever) newAuthenticatedRequest(endpoint string, uv url.Values) (*http.Request, error) {
+	header := http.Header{}
+	if ce.clientAuthenticator != nil {
+		if err := ce.clientAuthenticator.AuthenticateClient(endpoint, uv, header); err != nil {
+			return nil, errors.Wrap(err, "could not authenticate client")
+		}
+	}
+
+	request, err := newFormRequest(endpoint, uv)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	return request, nil
+}
+
+// newFormRequest builds a new form encoded POST http.Request for the passed
+// in endpoint
+func newFormRequest(endpoint string, uv url.Values) (*http.Request, error) {
 	euv := uv.Encode()
 
 	request, err := http.NewRequest("POST",
-		ce.oidcWellKnownEndpoints.TokenEndpoint,
+		endpoint,
 		strings.NewReader(euv),
 	)
 	if err != nil {
@@ -91,20 +236,90 @@ func (ce *TokenRetriever) newRefreshTokenRequest(req RefreshTokenExchangeRequest
 	uv.Set("client_id", req.ClientID)
 	uv.Set("refresh_token", req.RefreshToken)
 
-	euv := uv.Encode()
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+}
 
-	request, err := http.NewRequest("POST",
-		ce.oidcWellKnownEndpoints.TokenEndpoint,
-		strings.NewReader(euv),
-	)
-	if err != nil {
-		return nil, err
+// newClientCredentialsRequest builds a new ClientCredentialsExchangeRequest
+// wrapped in an http.Request
+func (ce *TokenRetriever) newClientCredentialsRequest(req ClientCredentialsExchangeRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("grant_type", "client_credentials")
+	uv.Set("client_id", req.ClientID)
+	uv.Set("audience", req.Audience)
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+}
+
+// newJWTBearerRequest builds a new JWTBearerExchangeRequest wrapped in an
+// http.Request
+func (ce *TokenRetriever) newJWTBearerRequest(req JWTBearerExchangeRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	uv.Set("client_id", req.ClientID)
+	uv.Set("assertion", req.Assertion)
+	uv.Set("audience", req.Audience)
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+}
+
+// newTokenExchangeRequest builds a new TokenExchangeRequest wrapped in an
+// http.Request
+func (ce *TokenRetriever) newTokenExchangeRequest(req TokenExchangeRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+	uv.Set("client_id", req.ClientID)
+	uv.Set("subject_token", req.SubjectToken)
+	uv.Set("subject_token_type", req.SubjectTokenType)
+	uv.Set("requested_token_type", req.RequestedTokenType)
+	uv.Set("audience", req.Audience)
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+}
+
+// newDeviceAuthorizationRequest builds a new DeviceAuthorizationRequest
+// wrapped in an http.Request
+func (ce *TokenRetriever) newDeviceAuthorizationRequest(req DeviceAuthorizationRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("client_id", req.ClientID)
+	uv.Set("audience", req.Audience)
+	uv.Set("scope", strings.Join(req.Scopes, " "))
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.DeviceAuthorizationEndpoint, uv)
+}
+
+// newDeviceCodeRequest builds a new DeviceCodeExchangeRequest wrapped in an
+// http.Request
+func (ce *TokenRetriever) newDeviceCodeRequest(req DeviceCodeExchangeRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	uv.Set("client_id", req.ClientID)
+	uv.Set("device_code", req.DeviceCode)
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.TokenEndpoint, uv)
+}
+
+// newPushedAuthorizationRequest builds a new pushed authorization request for
+// the authorization request <params> wrapped in an http.Request
+func (ce *TokenRetriever) newPushedAuthorizationRequest(params url.Values) (*http.Request, error) {
+	uv := url.Values{}
+	for key, values := range params {
+		uv[key] = values
 	}
 
-	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	request.Header.Add("Content-Length", strconv.Itoa(len(euv)))
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.PushedAuthorizationRequestEndpoint, uv)
+}
 
-	return request, nil
+// newTokenRevocationRequest builds a new TokenRevocationRequest wrapped in an
+// http.Request
+func (ce *TokenRetriever) newTokenRevocationRequest(req TokenRevocationRequest) (*http.Request, error) {
+	uv := url.Values{}
+	uv.Set("client_id", req.ClientID)
+	uv.Set("token", req.Token)
+	if req.TokenTypeHint != "" {
+		uv.Set("token_type_hint", req.TokenTypeHint)
+	}
+
+	return ce.newAuthenticatedRequest(ce.oidcWellKnownEndpoints.RevocationEndpoint, uv)
 }
 
 // ExchangeCode uses the AuthCodeExchangeRequest to exchange an authorization
@@ -115,19 +330,116 @@ func (ce *TokenRetriever) ExchangeCode(req AuthorizationCodeExchangeRequest) (*T
 		return nil, err
 	}
 
-	response, err := ce.transport.Do(request)
+	response, err := ce.do(request)
 	if err != nil {
 		return nil, err
 	}
 
-	return ce.handleAuthTokensResponse(response)
+	return ce.verifyIDToken(ce.handleAuthTokensResponse(response))
+}
+
+// do sends the request using the transport. When DPoP is in use a proof is
+// added to the request and the request is retried once with the nonce the
+// issuer asks for in a use_dpop_nonce error.
+func (ce *TokenRetriever) do(request *http.Request) (*http.Response, error) {
+	if ce.dpopProver == nil {
+		return ce.transport.Do(request)
+	}
+
+	response, err := ce.doWithDPoPProof(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isUseDPoPNonceError(response) {
+		return response, nil
+	}
+
+	retry := request.Clone(request.Context())
+	if request.GetBody != nil {
+		retry.Body, err = request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ce.doWithDPoPProof(retry)
+}
+
+// doWithDPoPProof adds a DPoP proof to the request, sends it and records any
+// new nonce sent by the issuer. A retrying transport gets a new proof for each
+// attempt as issuers reject a proof that was already used.
+func (ce *TokenRetriever) doWithDPoPProof(request *http.Request) (*http.Response, error) {
+	if err := ce.addDPoPProof(request); err != nil {
+		return nil, err
+	}
+
+	response, err := ce.transport.Do(withBeforeRetry(request, ce.addDPoPProof))
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce := response.Header.Get("DPoP-Nonce"); nonce != "" {
+		ce.dpopProver.SetNonce(nonce)
+	}
+
+	return response, nil
+}
+
+// addDPoPProof sets a new DPoP proof for the request
+func (ce *TokenRetriever) addDPoPProof(request *http.Request) error {
+	proof, err := ce.dpopProver.Proof(request.Method, request.URL.String())
+	if err != nil {
+		return err
+	}
+	request.Header.Set("DPoP", proof)
+
+	return nil
+}
+
+// isUseDPoPNonceError checks if the response is a use_dpop_nonce error. The
+// response body is left intact so that it can still be handled.
+func isUseDPoPNonceError(response *http.Response) bool {
+	if response.StatusCode != http.StatusBadRequest && response.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	if response.Header.Get("DPoP-Nonce") == "" {
+		return false
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	errResp := tokenErrorResponse{}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error == "use_dpop_nonce" {
+		return true
+	}
+
+	return strings.Contains(response.Header.Get("WWW-Authenticate"), `error="use_dpop_nonce"`)
 }
 
 // handleAuthTokensResponse takes care of checking an http.Response that has
 // auth tokens for errors and parsing the raw body to a TokenResult struct
 func (ce *TokenRetriever) handleAuthTokensResponse(resp *http.Response) (*TokenResult, error) {
+	atr, err := ce.decodeAuthTokensResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return atr.toTokenResult(), nil
+}
+
+// decodeAuthTokensResponse takes care of checking an http.Response that has
+// auth tokens for errors and parsing the raw body to an
+// AuthorizationTokenResponse struct
+func (ce *TokenRetriever) decodeAuthTokensResponse(resp *http.Response) (*AuthorizationTokenResponse, error) {
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, fmt.Errorf("A non-success status code was receveived: %d", resp.StatusCode)
+		return nil, newOAuthError(resp)
 	}
 
 	defer resp.Body.Close()
@@ -137,13 +449,46 @@ func (ce *TokenRetriever) handleAuthTokensResponse(resp *http.Response) (*TokenR
 	if err != nil {
 		return nil, err
 	}
+	atr.clockSkew = issuerClockSkew(resp.Header, time.Now())
+
+	return &atr, nil
+}
+
+// verifyIDToken verifies the ID token of the TokenResult before it is handed
+// out when an IDTokenVerifier is set
+func (ce *TokenRetriever) verifyIDToken(tokenResult *TokenResult, err error) (*TokenResult, error) {
+	if err != nil || ce.idTokenVerifier == nil || tokenResult.IDToken == "" {
+		return tokenResult, err
+	}
+
+	if err := ce.idTokenVerifier.verify(tokenResult.IDToken, tokenResult.ClockSkew); err != nil {
+		return nil, err
+	}
+
+	return tokenResult, nil
+}
 
+// toTokenResult converts the AuthorizationTokenResponse to a TokenResult
+func (atr *AuthorizationTokenResponse) toTokenResult() *TokenResult {
 	return &TokenResult{
 		AccessToken:  atr.AccessToken,
 		IDToken:      atr.IDToken,
 		RefreshToken: atr.RefreshToken,
 		ExpiresIn:    atr.ExpiresIn,
-	}, nil
+		TokenType:    atr.TokenType,
+		ClockSkew:    atr.clockSkew,
+	}
+}
+
+// issuerClockSkew works out how many seconds the issuer's clock is ahead of
+// <now> from the Date header. Zero is returned when there is no Date header.
+func issuerClockSkew(header http.Header, now time.Time) int64 {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+
+	return int64(date.Sub(now).Round(time.Second) / time.Second)
 }
 
 // ExchangeRefreshToken uses the RefreshTokenExchangeRequest to exchange a
@@ -154,10 +499,200 @@ func (ce *TokenRetriever) ExchangeRefreshToken(req RefreshTokenExchangeRequest)
 		return nil, err
 	}
 
-	response, err := ce.transport.Do(request)
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ce.verifyIDToken(ce.handleAuthTokensResponse(response))
+}
+
+// ExchangeClientCredentials uses the ClientCredentialsExchangeRequest to
+// exchange client credentials for tokens
+func (ce *TokenRetriever) ExchangeClientCredentials(req ClientCredentialsExchangeRequest) (*TokenResult, error) {
+	request, err := ce.newClientCredentialsRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ce.handleAuthTokensResponse(response)
+}
+
+// ExchangeJWTBearer uses the JWTBearerExchangeRequest to exchange a JWT
+// assertion for tokens
+func (ce *TokenRetriever) ExchangeJWTBearer(req JWTBearerExchangeRequest) (*TokenResult, error) {
+	request, err := ce.newJWTBearerRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
 	if err != nil {
 		return nil, err
 	}
 
 	return ce.handleAuthTokensResponse(response)
 }
+
+// ExchangeToken uses the TokenExchangeRequest to exchange a subject token for
+// a new token. When an ID token was issued it is returned as the IDToken of
+// the TokenResult.
+func (ce *TokenRetriever) ExchangeToken(req TokenExchangeRequest) (*TokenResult, error) {
+	request, err := ce.newTokenExchangeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	atr, err := ce.decodeAuthTokensResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenResult := atr.toTokenResult()
+	if atr.IssuedTokenType == TokenTypeIDToken && tokenResult.IDToken == "" {
+		tokenResult.IDToken = atr.AccessToken
+		tokenResult.AccessToken = ""
+	}
+
+	return tokenResult, nil
+}
+
+// RequestDeviceCode uses the DeviceAuthorizationRequest to ask the device
+// authorization endpoint for a device code and user code
+func (ce *TokenRetriever) RequestDeviceCode(req DeviceAuthorizationRequest) (*DeviceAuthorizationResponse, error) {
+	request, err := ce.newDeviceAuthorizationRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ce.handleDeviceAuthorizationResponse(response)
+}
+
+// handleDeviceAuthorizationResponse takes care of checking an http.Response
+// from the device authorization endpoint for errors and parsing the raw body
+// to a DeviceAuthorizationResponse struct
+func (ce *TokenRetriever) handleDeviceAuthorizationResponse(resp *http.Response) (*DeviceAuthorizationResponse, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newOAuthError(resp)
+	}
+
+	defer resp.Body.Close()
+
+	dar := DeviceAuthorizationResponse{}
+	err := json.NewDecoder(resp.Body).Decode(&dar)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dar, nil
+}
+
+// PushAuthorizationRequest pushes the authorization request <params> to the
+// pushed authorization request endpoint as defined by RFC 9126. The returned
+// request_uri is used in place of the parameters when opening the
+// authorization endpoint.
+func (ce *TokenRetriever) PushAuthorizationRequest(params url.Values) (*PushedAuthorizationResponse, error) {
+	request, err := ce.newPushedAuthorizationRequest(params)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ce.handlePushedAuthorizationResponse(response)
+}
+
+// handlePushedAuthorizationResponse takes care of checking an http.Response
+// from the pushed authorization request endpoint for errors and parsing the
+// raw body to a PushedAuthorizationResponse struct
+func (ce *TokenRetriever) handlePushedAuthorizationResponse(resp *http.Response) (*PushedAuthorizationResponse, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newOAuthError(resp)
+	}
+
+	defer resp.Body.Close()
+
+	par := PushedAuthorizationResponse{}
+	err := json.NewDecoder(resp.Body).Decode(&par)
+	if err != nil {
+		return nil, err
+	}
+
+	if par.RequestURI == "" {
+		return nil, errors.New("the pushed authorization response did not include a request_uri")
+	}
+
+	return &par, nil
+}
+
+// RevokeToken uses the TokenRevocationRequest to revoke a token at the
+// revocation endpoint as defined by RFC 7009
+func (ce *TokenRetriever) RevokeToken(req TokenRevocationRequest) error {
+	request, err := ce.newTokenRevocationRequest(req)
+	if err != nil {
+		return err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return newOAuthError(response)
+	}
+	response.Body.Close()
+
+	return nil
+}
+
+// ExchangeDeviceCode uses the DeviceCodeExchangeRequest to exchange a device
+// code for tokens. ErrAuthorizationPending or ErrSlowDown are returned while
+// the user has not yet completed authorization.
+func (ce *TokenRetriever) ExchangeDeviceCode(req DeviceCodeExchangeRequest) (*TokenResult, error) {
+	request, err := ce.newDeviceCodeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ce.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return ce.verifyIDToken(ce.handleDeviceTokenResponse(response))
+}
+
+// handleDeviceTokenResponse maps the polling errors defined by RFC 8628 to
+// ErrAuthorizationPending and ErrSlowDown and otherwise handles the response
+// like any other token response
+func (ce *TokenRetriever) handleDeviceTokenResponse(resp *http.Response) (*TokenResult, error) {
+	tokenResult, err := ce.handleAuthTokensResponse(resp)
+
+	switch {
+	case errors.Is(err, ErrAuthorizationPending):
+		return nil, ErrAuthorizationPending
+	case errors.Is(err, ErrSlowDown):
+		return nil, ErrSlowDown
+	}
+
+	return tokenResult, err
+}
@@ -2,10 +2,18 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -35,6 +43,7 @@ var _ = Describe("CodetokenExchanger", func() {
 
 			Expect(result.Header.Get("Content-Type")).To(Equal("application/x-www-form-urlencoded"))
 			Expect(result.Header.Get("Content-Length")).To(Equal("117"))
+			Expect(isReplayable(result)).To(BeFalse())
 		})
 
 		It("returns an error when NewRequest returns an error", func() {
@@ -117,8 +126,514 @@ var _ = Describe("CodetokenExchanger", func() {
 			Expect(err.Error()).To(Equal("parse ://issuer/oauth/token: missing protocol scheme"))
 		})
 	})
+
+	Describe("newClientCredentialsRequest", func() {
+		It("creates the request", func() {
+			tokenRetriever := TokenRetriever{oidcWellKnownEndpoints: OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}}
+
+			result, err := tokenRetriever.newClientCredentialsRequest(ClientCredentialsExchangeRequest{
+				ClientID: "clientID",
+				Audience: "audience",
+			})
+
+			result.ParseForm()
+
+			Expect(err).To(BeNil())
+			Expect(result.FormValue("grant_type")).To(Equal("client_credentials"))
+			Expect(result.FormValue("client_id")).To(Equal("clientID"))
+			Expect(result.FormValue("audience")).To(Equal("audience"))
+			Expect(result.URL.String()).To(Equal("https://issuer/oauth/token"))
+		})
+	})
+
+	Describe("newJWTBearerRequest", func() {
+		It("creates the request", func() {
+			tokenRetriever := TokenRetriever{oidcWellKnownEndpoints: OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}}
+
+			result, err := tokenRetriever.newJWTBearerRequest(JWTBearerExchangeRequest{
+				ClientID:  "clientID",
+				Assertion: "assertion",
+				Audience:  "audience",
+			})
+
+			result.ParseForm()
+
+			Expect(err).To(BeNil())
+			Expect(result.FormValue("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:jwt-bearer"))
+			Expect(result.FormValue("client_id")).To(Equal("clientID"))
+			Expect(result.FormValue("assertion")).To(Equal("assertion"))
+			Expect(result.FormValue("audience")).To(Equal("audience"))
+			Expect(result.URL.String()).To(Equal("https://issuer/oauth/token"))
+		})
+	})
+
+	Describe("newTokenExchangeRequest", func() {
+		It("creates the request", func() {
+			tokenRetriever := TokenRetriever{oidcWellKnownEndpoints: OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}}
+
+			result, err := tokenRetriever.newTokenExchangeRequest(TokenExchangeRequest{
+				ClientID:           "clientID",
+				SubjectToken:       "subject",
+				SubjectTokenType:   TokenTypeAccessToken,
+				RequestedTokenType: TokenTypeIDToken,
+				Audience:           "audience",
+			})
+
+			result.ParseForm()
+
+			Expect(err).To(BeNil())
+			Expect(result.FormValue("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:token-exchange"))
+			Expect(result.FormValue("client_id")).To(Equal("clientID"))
+			Expect(result.FormValue("subject_token")).To(Equal("subject"))
+			Expect(result.FormValue("subject_token_type")).To(Equal("urn:ietf:params:oauth:token-type:access_token"))
+			Expect(result.FormValue("requested_token_type")).To(Equal("urn:ietf:params:oauth:token-type:id_token"))
+			Expect(result.FormValue("audience")).To(Equal("audience"))
+			Expect(result.URL.String()).To(Equal("https://issuer/oauth/token"))
+		})
+	})
+
+	Describe("ExchangeToken", func() {
+		It("returns an issued id token as the IDToken", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}, &mockTransport{
+				Response: buildResponse(200, AuthorizationTokenResponse{
+					AccessToken:     "idToken",
+					IssuedTokenType: TokenTypeIDToken,
+				}),
+			}, nil)
+
+			result, err := tokenRetriever.ExchangeToken(TokenExchangeRequest{RequestedTokenType: TokenTypeIDToken})
+
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&TokenResult{IDToken: "idToken"}))
+		})
+
+		It("returns an issued access token as the AccessToken", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}, &mockTransport{
+				Response: buildResponse(200, AuthorizationTokenResponse{
+					AccessToken:     "accessToken",
+					IssuedTokenType: TokenTypeAccessToken,
+					ExpiresIn:       60,
+				}),
+			}, nil)
+
+			result, err := tokenRetriever.ExchangeToken(TokenExchangeRequest{})
+
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&TokenResult{AccessToken: "accessToken", ExpiresIn: 60}))
+		})
+	})
+
+	Describe("PushAuthorizationRequest", func() {
+		It("posts the params to the pushed authorization request endpoint", func() {
+			transport := &mockTransport{
+				Response: buildResponse(201, PushedAuthorizationResponse{RequestURI: "urn:example", ExpiresIn: 60}),
+			}
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{PushedAuthorizationRequestEndpoint: "https://issuer/oauth/par"}, transport, nil)
+
+			result, err := tokenRetriever.PushAuthorizationRequest(url.Values{"client_id": []string{"clientID"}, "state": []string{"abc"}})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequestURI).To(Equal("urn:example"))
+			Expect(result.ExpiresIn).To(Equal(60))
+
+			request := transport.Requests[0]
+			Expect(request.URL.String()).To(Equal("https://issuer/oauth/par"))
+			Expect(request.ParseForm()).To(Succeed())
+			Expect(request.PostForm.Get("client_id")).To(Equal("clientID"))
+			Expect(request.PostForm.Get("state")).To(Equal("abc"))
+		})
+
+		It("returns the error sent by the issuer", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{PushedAuthorizationRequestEndpoint: "https://issuer/oauth/par"}, &mockTransport{
+				Response: buildResponse(400, tokenErrorResponse{Error: "invalid_request", ErrorDescription: "bad redirect_uri"}),
+			}, nil)
+
+			_, err := tokenRetriever.PushAuthorizationRequest(url.Values{})
+
+			Expect(err.Error()).To(Equal("invalid_request: bad redirect_uri"))
+		})
+
+		It("returns an error when no request_uri is returned", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{PushedAuthorizationRequestEndpoint: "https://issuer/oauth/par"}, &mockTransport{
+				Response: buildResponse(201, PushedAuthorizationResponse{}),
+			}, nil)
+
+			_, err := tokenRetriever.PushAuthorizationRequest(url.Values{})
+
+			Expect(err.Error()).To(Equal("the pushed authorization response did not include a request_uri"))
+		})
+	})
+
+	Describe("ID token verification", func() {
+		It("returns an error instead of tokens with an invalid ID token", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, &mockTransport{
+				Response: buildResponse(200, AuthorizationTokenResponse{AccessToken: "at", IDToken: "not a jwt"}),
+			}, nil)
+			tokenRetriever.idTokenVerifier = NewIDTokenVerifier("https://issuer/", "clientID", &mockKeySet{})
+
+			result, err := tokenRetriever.ExchangeCode(AuthorizationCodeExchangeRequest{})
+
+			Expect(result).To(BeNil())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("does not verify when the response has no ID token", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, &mockTransport{
+				Response: buildResponse(200, AuthorizationTokenResponse{AccessToken: "at"}),
+			}, nil)
+			tokenRetriever.idTokenVerifier = NewIDTokenVerifier("https://issuer/", "clientID", &mockKeySet{})
+
+			result, err := tokenRetriever.ExchangeRefreshToken(RefreshTokenExchangeRequest{})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.AccessToken).To(Equal("at"))
+		})
+	})
+
+	Describe("RevokeToken", func() {
+		It("posts the token to the revocation endpoint", func() {
+			transport := &mockTransport{Response: buildResponse(200, nil)}
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{RevocationEndpoint: "https://issuer/oauth/revoke"}, transport, nil)
+
+			err := tokenRetriever.RevokeToken(TokenRevocationRequest{ClientID: "clientID", Token: "rt", TokenTypeHint: "refresh_token"})
+
+			Expect(err).NotTo(HaveOccurred())
+			request := transport.Requests[0]
+			Expect(request.URL.String()).To(Equal("https://issuer/oauth/revoke"))
+			Expect(request.ParseForm()).To(Succeed())
+			Expect(request.PostForm).To(Equal(url.Values{
+				"client_id":       []string{"clientID"},
+				"token":           []string{"rt"},
+				"token_type_hint": []string{"refresh_token"},
+			}))
+		})
+
+		It("returns the error sent by the issuer", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{RevocationEndpoint: "https://issuer/oauth/revoke"}, &mockTransport{
+				Response: buildResponse(400, tokenErrorResponse{Error: "unsupported_token_type", ErrorDescription: "nope"}),
+			}, nil)
+
+			err := tokenRetriever.RevokeToken(TokenRevocationRequest{ClientID: "clientID", Token: "rt"})
+
+			Expect(err.Error()).To(Equal("unsupported_token_type: nope"))
+		})
+
+		It("returns an error for other failures", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{RevocationEndpoint: "https://issuer/oauth/revoke"}, &mockTransport{
+				Response: buildResponse(503, nil),
+			}, nil)
+
+			err := tokenRetriever.RevokeToken(TokenRevocationRequest{ClientID: "clientID", Token: "rt"})
+
+			Expect(err.Error()).To(Equal("A non-success status code was receveived: 503"))
+		})
+	})
+
+	Describe("DPoP", func() {
+		var key *ecdsa.PrivateKey
+		var tokenRetriever *TokenRetriever
+		var transport *mockTransport
+
+		BeforeEach(func() {
+			var err error
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			transport = &mockTransport{}
+			tokenRetriever = NewTokenRetriever(OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}, transport, nil)
+			tokenRetriever.dpopProver = NewDPoPProver(key)
+		})
+
+		parseProof := func(request *http.Request) jwt.MapClaims {
+			claims := jwt.MapClaims{}
+			_, err := jwt.ParseWithClaims(request.Header.Get("DPoP"), claims, func(t *jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return claims
+		}
+
+		It("adds a proof to the request and keeps the token type", func() {
+			transport.Response = buildResponse(200, AuthorizationTokenResponse{AccessToken: "at", TokenType: TokenTypeDPoP})
+
+			result, err := tokenRetriever.ExchangeRefreshToken(RefreshTokenExchangeRequest{ClientID: "clientID", RefreshToken: "rt"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.TokenType).To(Equal(TokenTypeDPoP))
+			Expect(transport.Requests).To(HaveLen(1))
+			claims := parseProof(transport.Requests[0])
+			Expect(claims["htm"]).To(Equal("POST"))
+			Expect(claims["htu"]).To(Equal("https://issuer/oauth/token"))
+		})
+
+		It("retries with the nonce the issuer asks for", func() {
+			nonceResponse := buildResponse(400, tokenErrorResponse{Error: "use_dpop_nonce"})
+			nonceResponse.Header = http.Header{"Dpop-Nonce": []string{"n-1"}}
+			transport.Responses = []*http.Response{nonceResponse}
+			transport.Response = buildResponse(200, AuthorizationTokenResponse{AccessToken: "at", TokenType: TokenTypeDPoP})
+
+			result, err := tokenRetriever.ExchangeRefreshToken(RefreshTokenExchangeRequest{ClientID: "clientID", RefreshToken: "rt"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.AccessToken).To(Equal("at"))
+			Expect(transport.Requests).To(HaveLen(2))
+			Expect(parseProof(transport.Requests[0])).NotTo(HaveKey("nonce"))
+			Expect(parseProof(transport.Requests[1])["nonce"]).To(Equal("n-1"))
+			body, err := ioutil.ReadAll(transport.Requests[1].Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring("refresh_token=rt"))
+		})
+
+		It("signs a new proof for each attempt of a retrying transport", func() {
+			transport.Responses = []*http.Response{buildResponse(503, nil)}
+			transport.Response = buildResponse(200, AuthorizationTokenResponse{AccessToken: "at", TokenType: TokenTypeDPoP})
+			retry := NewRetryTransport(transport, time.Minute)
+			retry.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+			tokenRetriever.transport = retry
+
+			_, err := tokenRetriever.ExchangeRefreshToken(RefreshTokenExchangeRequest{ClientID: "clientID", RefreshToken: "rt"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(transport.Requests).To(HaveLen(2))
+			Expect(parseProof(transport.Requests[0])["jti"]).NotTo(BeEmpty())
+			Expect(parseProof(transport.Requests[1])["jti"]).NotTo(Equal(parseProof(transport.Requests[0])["jti"]))
+		})
+
+		It("does not retry other errors", func() {
+			transport.Response = buildResponse(400, tokenErrorResponse{Error: "invalid_grant"})
+			transport.Response.Header = http.Header{"Dpop-Nonce": []string{"n-1"}}
+
+			_, err := tokenRetriever.ExchangeRefreshToken(RefreshTokenExchangeRequest{ClientID: "clientID", RefreshToken: "rt"})
+
+			Expect(err).To(HaveOccurred())
+			Expect(transport.Requests).To(HaveLen(1))
+		})
+	})
+
+	Describe("handleAuthTokensResponse", func() {
+		It("returns an OAuthError that matches ErrInvalidGrant when the refresh token is rejected", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, nil, nil)
+
+			_, err := tokenRetriever.handleAuthTokensResponse(buildResponse(400, tokenErrorResponse{
+				Error:            "invalid_grant",
+				ErrorDescription: "Unknown or invalid refresh token.",
+			}))
+
+			Expect(err).To(MatchError(ErrInvalidGrant))
+			Expect(err.Error()).To(Equal("invalid_grant: Unknown or invalid refresh token."))
+		})
+
+		It("returns the error sent by the issuer with the status and WWW-Authenticate header", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, nil, nil)
+			response := buildResponse(401, tokenErrorResponse{
+				Error:            "invalid_client",
+				ErrorDescription: "unknown client",
+				ErrorURI:         "https://issuer/docs/errors",
+			})
+			response.Header = http.Header{"Www-Authenticate": []string{`Basic realm="issuer"`}}
+
+			_, err := tokenRetriever.handleAuthTokensResponse(response)
+
+			var oauthErr *OAuthError
+			Expect(errors.As(err, &oauthErr)).To(BeTrue())
+			Expect(oauthErr).To(Equal(&OAuthError{
+				Code:            "invalid_client",
+				Description:     "unknown client",
+				URI:             "https://issuer/docs/errors",
+				StatusCode:      401,
+				WWWAuthenticate: `Basic realm="issuer"`,
+			}))
+			Expect(err.Error()).To(Equal("invalid_client: unknown client"))
+		})
+
+		It("records how far the issuer's clock is ahead from the Date header", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, nil, nil)
+			response := buildResponse(200, AuthorizationTokenResponse{AccessToken: "at"})
+			response.Header = http.Header{"Date": []string{time.Now().Add(-2 * time.Minute).UTC().Format(http.TimeFormat)}}
+
+			result, err := tokenRetriever.handleAuthTokensResponse(response)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ClockSkew).To(BeNumerically("~", -120, 2))
+		})
+
+		It("returns the status code when there is no error body", func() {
+			tokenRetriever := NewTokenRetriever(OIDCWellKnownEndpoints{}, nil, nil)
+
+			_, err := tokenRetriever.handleAuthTokensResponse(buildResponse(400, nil))
+
+			Expect(err.Error()).To(Equal("A non-success status code was receveived: 400"))
+		})
+	})
+
+	Describe("newAuthenticatedRequest", func() {
+		It("adds client authentication to the request", func() {
+			tokenRetriever := NewTokenRetriever(
+				OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"},
+				nil,
+				&clientSecretBasic{clientID: "clientID", secret: func() (string, error) { return "secret", nil }})
+
+			result, err := tokenRetriever.newRefreshTokenRequest(RefreshTokenExchangeRequest{
+				ClientID:     "clientID",
+				RefreshToken: "refreshToken",
+			})
+
+			Expect(err).To(BeNil())
+			username, password, ok := result.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(username).To(Equal("clientID"))
+			Expect(password).To(Equal("secret"))
+		})
+
+		It("returns an error when client authentication fails", func() {
+			tokenRetriever := NewTokenRetriever(
+				OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"},
+				nil,
+				&clientSecretPost{clientID: "clientID", secret: func() (string, error) { return "", errors.New("uh oh") }})
+
+			result, err := tokenRetriever.newRefreshTokenRequest(RefreshTokenExchangeRequest{})
+
+			Expect(result).To(BeNil())
+			Expect(err.Error()).To(Equal("could not authenticate client: could not read client secret: uh oh"))
+		})
+	})
+
+	Describe("newDeviceAuthorizationRequest", func() {
+		It("creates the request", func() {
+			tokenRetriever := TokenRetriever{oidcWellKnownEndpoints: OIDCWellKnownEndpoints{DeviceAuthorizationEndpoint: "https://issuer/oauth/device/code"}}
+			deviceRequest := DeviceAuthorizationRequest{
+				ClientID: "clientID",
+				Audience: "audience",
+				Scopes:   []string{"openid", "email"},
+			}
+
+			result, err := tokenRetriever.newDeviceAuthorizationRequest(deviceRequest)
+
+			result.ParseForm()
+
+			Expect(err).To(BeNil())
+			Expect(result.FormValue("client_id")).To(Equal("clientID"))
+			Expect(result.FormValue("audience")).To(Equal("audience"))
+			Expect(result.FormValue("scope")).To(Equal("openid email"))
+			Expect(result.URL.String()).To(Equal("https://issuer/oauth/device/code"))
+			Expect(result.Header.Get("Content-Type")).To(Equal("application/x-www-form-urlencoded"))
+		})
+	})
+
+	Describe("handleDeviceAuthorizationResponse", func() {
+		It("handles the response", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(200, DeviceAuthorizationResponse{
+				DeviceCode:      "deviceCode",
+				UserCode:        "userCode",
+				VerificationURI: "https://issuer/activate",
+				ExpiresIn:       600,
+				Interval:        5,
+			})
+
+			result, err := tokenRetriever.handleDeviceAuthorizationResponse(response)
+
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&DeviceAuthorizationResponse{
+				DeviceCode:      "deviceCode",
+				UserCode:        "userCode",
+				VerificationURI: "https://issuer/activate",
+				ExpiresIn:       600,
+				Interval:        5,
+			}))
+		})
+
+		It("returns error when status code is not successful", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(500, nil)
+
+			result, err := tokenRetriever.handleDeviceAuthorizationResponse(response)
+
+			Expect(result).To(BeNil())
+			Expect(err.Error()).To(Equal("A non-success status code was receveived: 500"))
+		})
+	})
+
+	Describe("newDeviceCodeRequest", func() {
+		It("creates the request", func() {
+			tokenRetriever := TokenRetriever{oidcWellKnownEndpoints: OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/oauth/token"}}
+
+			result, err := tokenRetriever.newDeviceCodeRequest(DeviceCodeExchangeRequest{
+				ClientID:   "clientID",
+				DeviceCode: "deviceCode",
+			})
+
+			result.ParseForm()
+
+			Expect(err).To(BeNil())
+			Expect(result.FormValue("grant_type")).To(Equal("urn:ietf:params:oauth:grant-type:device_code"))
+			Expect(result.FormValue("client_id")).To(Equal("clientID"))
+			Expect(result.FormValue("device_code")).To(Equal("deviceCode"))
+			Expect(result.URL.String()).To(Equal("https://issuer/oauth/token"))
+		})
+	})
+
+	Describe("handleDeviceTokenResponse", func() {
+		It("handles a successful response", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(200, AuthorizationTokenResponse{AccessToken: "myAccessToken"})
+
+			result, err := tokenRetriever.handleDeviceTokenResponse(response)
+
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&TokenResult{AccessToken: "myAccessToken"}))
+		})
+
+		It("returns ErrAuthorizationPending when authorization is pending", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(400, tokenErrorResponse{Error: "authorization_pending"})
+
+			result, err := tokenRetriever.handleDeviceTokenResponse(response)
+
+			Expect(result).To(BeNil())
+			Expect(err).To(Equal(ErrAuthorizationPending))
+		})
+
+		It("returns ErrSlowDown when asked to slow down", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(400, tokenErrorResponse{Error: "slow_down"})
+
+			result, err := tokenRetriever.handleDeviceTokenResponse(response)
+
+			Expect(result).To(BeNil())
+			Expect(err).To(Equal(ErrSlowDown))
+		})
+
+		It("returns other errors with their description", func() {
+			tokenRetriever := TokenRetriever{}
+			response := buildResponse(400, tokenErrorResponse{Error: "expired_token", ErrorDescription: "too late"})
+
+			result, err := tokenRetriever.handleDeviceTokenResponse(response)
+
+			Expect(result).To(BeNil())
+			Expect(err.Error()).To(Equal("expired_token: too late"))
+		})
+	})
 })
 
+type mockTransport struct {
+	Requests []*http.Request
+	Response *http.Response
+	// Responses are returned in order before falling back to Response
+	Responses []*http.Response
+	Error     error
+}
+
+func (t *mockTransport) Do(request *http.Request) (*http.Response, error) {
+	t.Requests = append(t.Requests, request)
+	if len(t.Responses) > 0 {
+		response := t.Responses[0]
+		t.Responses = t.Responses[1:]
+		return response, t.Error
+	}
+	return t.Response, t.Error
+}
+
 func buildResponse(statusCode int, body interface{}) *http.Response {
 	b, err := json.Marshal(body)
 	if err != nil {
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -10,26 +13,62 @@ import (
 type cachingProvider interface {
 	GetTokens() (*TokenResult, error)
 	CacheTokens(*TokenResult) error
+	ClearTokens() error
 }
 
-type issuerTokenProvider interface {
+// IssuerTokenProvider abstracts getting tokens from the issuer when there are
+// no valid tokens in the cache
+type IssuerTokenProvider interface {
 	FromRefreshToken(refreshToken string) (*TokenResult, error)
 	Authenticate() (*TokenResult, error)
 }
 
+// CachingOptions holds the optional configuration of a CachingTokenProvider
+type CachingOptions struct {
+	// RefreshFailurePolicy decides which refresh failures fall back to
+	// authenticating again. All but transient failures do when it is empty.
+	RefreshFailurePolicy RefreshFailurePolicy
+	// Output is where the reason for authenticating again is reported
+	Output io.Writer
+	// RefreshAhead refreshes tokens that expire within it so that kubectl is
+	// not handed a token that expires while it is being used. The expiry
+	// reported for a token is brought forward by it as well.
+	RefreshAhead time.Duration
+	// ClockSkew is how far the local clock is allowed to be behind the
+	// issuer's clock. Tokens are treated as expiring that much earlier.
+	ClockSkew time.Duration
+	// DetectClockSkew corrects the expiry of JWTs for the difference between
+	// the local clock and the issuer's clock that was seen in the Date header
+	// when the tokens were received
+	DetectClockSkew bool
+}
+
+// DefaultRefreshAhead is the refresh ahead window used by the auth command
+const DefaultRefreshAhead = 60 * time.Second
+
 // CachingTokenProvider satisfies the cmd.tokenProvider interface and is a
 // token provider that uses a cache to store tokens
 type CachingTokenProvider struct {
 	cache               cachingProvider
-	issuerTokenProvider issuerTokenProvider
+	issuerTokenProvider IssuerTokenProvider
+	options             CachingOptions
+	now                 func() time.Time
 }
 
 // NewCachingTokenProvider builds a new CachingTokenProvider using the passed
 // in interface satisfiers
-func NewCachingTokenProvider(cache cachingProvider, issuerTokenProvider issuerTokenProvider) *CachingTokenProvider {
+func NewCachingTokenProvider(cache cachingProvider, issuerTokenProvider IssuerTokenProvider) *CachingTokenProvider {
+	return NewCachingTokenProviderWithOptions(cache, issuerTokenProvider, CachingOptions{})
+}
+
+// NewCachingTokenProviderWithOptions builds a new CachingTokenProvider that
+// is configured by <options>
+func NewCachingTokenProviderWithOptions(cache cachingProvider, issuerTokenProvider IssuerTokenProvider, options CachingOptions) *CachingTokenProvider {
 	return &CachingTokenProvider{
 		cache:               cache,
 		issuerTokenProvider: issuerTokenProvider,
+		options:             options,
+		now:                 time.Now,
 	}
 }
 
@@ -44,6 +83,7 @@ func (c *CachingTokenProvider) getTokenResult(shouldRefresh func(TokenResult) bo
 		if err != nil {
 			return nil, err
 		}
+		c.setExpiresAt(tokenResult)
 	}
 
 	err = c.cache.CacheTokens(tokenResult)
@@ -57,37 +97,84 @@ func (c *CachingTokenProvider) getTokenResult(shouldRefresh func(TokenResult) bo
 // GetIDToken returns an id token using the cache and falls back to an
 // issuer token provider if the cache is empty
 func (c *CachingTokenProvider) GetIDToken() (string, error) {
-	isIDTokenValid := func(tokenResult TokenResult) bool { return isValidToken(tokenResult.IDToken) }
+	token, _, err := c.GetIDTokenWithExpiry()
+	return token, err
+}
+
+// GetIDTokenWithExpiry works like GetIDToken and also returns when the id
+// token expires. The expiry is zero when it is not known.
+func (c *CachingTokenProvider) GetIDTokenWithExpiry() (string, time.Time, error) {
+	isIDTokenValid := func(tokenResult TokenResult) bool {
+		return c.isFresh(tokenResult.IDToken, 0, tokenResult.ClockSkew)
+	}
 	tokenResult, err := c.getTokenResult(isIDTokenValid)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return tokenResult.IDToken, nil
+	return tokenResult.IDToken, c.reportedExpiry(tokenResult.IDToken, 0, tokenResult.ClockSkew), nil
 }
 
 // GetAccessToken returns an access token using the cache and falls back to an
 // issuer token provider if the cache is empty
 func (c *CachingTokenProvider) GetAccessToken() (string, error) {
-	isAccessTokenValid := func(tokenResult TokenResult) bool { return isValidToken(tokenResult.AccessToken) }
+	token, _, err := c.GetAccessTokenWithExpiry()
+	return token, err
+}
+
+// GetAccessTokenWithExpiry works like GetAccessToken and also returns when
+// the access token expires. The expiry is zero when it is not known.
+func (c *CachingTokenProvider) GetAccessTokenWithExpiry() (string, time.Time, error) {
+	isAccessTokenValid := func(tokenResult TokenResult) bool {
+		return c.isFresh(tokenResult.AccessToken, tokenResult.ExpiresAt, tokenResult.ClockSkew)
+	}
 	tokenResult, err := c.getTokenResult(isAccessTokenValid)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return tokenResult.AccessToken, nil
+	return tokenResult.AccessToken, c.reportedExpiry(tokenResult.AccessToken, tokenResult.ExpiresAt, tokenResult.ClockSkew), nil
 }
 
-func (c *CachingTokenProvider) getRefreshToken(refreshToken string) *TokenResult {
-	// TODO: log the refreshErr somewhere
+// getRefreshToken refreshes the tokens. The refresh token the issuer rotated
+// to is kept and the old one is only reused when no new one was issued. No
+// TokenResult is returned when the user should authenticate again instead.
+func (c *CachingTokenProvider) getRefreshToken(refreshToken string) (*TokenResult, error) {
 	tokenResult, refreshErr := c.issuerTokenProvider.FromRefreshToken(refreshToken)
 	if refreshErr != nil {
-		return nil
+		return nil, c.handleRefreshFailure(refreshErr)
+	}
+
+	if tokenResult.RefreshToken == "" {
+		tokenResult.RefreshToken = refreshToken
 	}
+	c.setExpiresAt(tokenResult)
+
+	return tokenResult, nil
+}
 
-	tokenResult.RefreshToken = refreshToken
+// handleRefreshFailure decides, using the RefreshFailurePolicy, if the user
+// authenticates again or if the refresh error is returned. When the refresh
+// token is rejected, for example because the issuer detected that a rotated
+// refresh token was reused, the cache is cleared.
+func (c *CachingTokenProvider) handleRefreshFailure(refreshErr error) error {
+	failure := ClassifyRefreshError(refreshErr)
+
+	if failure == RefreshRejected {
+		if err := c.cache.ClearTokens(); err != nil {
+			return errors.Wrap(err, "could not clear the rejected tokens from the cache")
+		}
+	}
 
-	return tokenResult
+	if !c.options.RefreshFailurePolicy.reauthenticates(failure) {
+		return errors.Wrapf(refreshErr, "could not refresh the tokens as %s", failure)
+	}
+
+	if failure != RefreshNotSupported && c.options.Output != nil {
+		fmt.Fprintf(c.options.Output, "Could not refresh the tokens as %s, logging in again: %s\n", failure, refreshErr)
+	}
+
+	return nil
 }
 
 func (c *CachingTokenProvider) refreshFromCache(isTokenValid func(TokenResult) bool) (*TokenResult, error) {
@@ -108,17 +195,88 @@ func (c *CachingTokenProvider) refreshFromCache(isTokenValid func(TokenResult) b
 		return nil, nil
 	}
 
-	return c.getRefreshToken(tokenResult.RefreshToken), nil
+	return c.getRefreshToken(tokenResult.RefreshToken)
+}
+
+// setExpiresAt records when the access token of a newly received TokenResult
+// expires as ExpiresIn is relative to when it was received
+func (c *CachingTokenProvider) setExpiresAt(tokenResult *TokenResult) {
+	if tokenResult != nil && tokenResult.ExpiresIn > 0 && tokenResult.ExpiresAt == 0 {
+		tokenResult.ExpiresAt = c.now().Add(time.Duration(tokenResult.ExpiresIn) * time.Second).Unix()
+	}
+}
+
+// isFresh checks that the token does not expire within the refresh ahead
+// window and clock skew allowance. <expiresAt> is used for opaque tokens.
+// Tokens whose expiry is not known are never fresh.
+func (c *CachingTokenProvider) isFresh(token string, expiresAt int64, clockSkew int64) bool {
+	expiry := c.localExpiry(token, expiresAt, clockSkew)
+	if expiry.IsZero() {
+		return false
+	}
+
+	return !c.now().Add(c.options.RefreshAhead + c.options.ClockSkew).After(expiry)
+}
+
+// reportedExpiry is the expiry handed to kubectl. It is brought forward by the
+// refresh ahead window and clock skew allowance, unless that would put it in
+// the past, so that kubectl asks for a new token when it would be refreshed.
+func (c *CachingTokenProvider) reportedExpiry(token string, expiresAt int64, clockSkew int64) time.Time {
+	expiry := c.localExpiry(token, expiresAt, clockSkew)
+	if expiry.IsZero() {
+		return expiry
+	}
+
+	if early := expiry.Add(-c.options.RefreshAhead - c.options.ClockSkew); early.After(c.now()) {
+		return early
+	}
+
+	return expiry
+}
+
+// localExpiry is when the token expires on the local clock. The exp claim of
+// a JWT is corrected for the detected clock skew while <expiresAt>, which is
+// used for opaque tokens, already is local time. The zero time is returned
+// when the expiry is not known.
+func (c *CachingTokenProvider) localExpiry(token string, expiresAt int64, clockSkew int64) time.Time {
+	if exp, ok := jwtExpiry(token); ok {
+		return c.localTime(exp, clockSkew)
+	}
+
+	if expiresAt > 0 {
+		return time.Unix(expiresAt, 0)
+	}
+
+	return time.Time{}
 }
 
-// isValidToken checks to see if the token is valid and has not expired
-func isValidToken(token string) bool {
+// localTime converts a time read from a token issued by the issuer to the
+// local clock when clock skew detection is turned on
+func (c *CachingTokenProvider) localTime(t time.Time, clockSkew int64) time.Time {
+	if !c.options.DetectClockSkew {
+		return t
+	}
+
+	return t.Add(-time.Duration(clockSkew) * time.Second)
+}
+
+// jwtExpiry gets the expiry from the exp claim of a JWT
+func jwtExpiry(token string) (time.Time, bool) {
 	p := jwt.Parser{}
 	claims := jwt.MapClaims{}
 
 	if _, _, err := p.ParseUnverified(token, claims); err != nil {
-		return false
+		return time.Time{}, false
+	}
+
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0), true
+	case json.Number:
+		if v, err := exp.Int64(); err == nil {
+			return time.Unix(v, 0), true
+		}
 	}
 
-	return claims.VerifyExpiresAt(time.Now().Unix(), true)
+	return time.Time{}, false
 }
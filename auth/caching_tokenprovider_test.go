@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
+	"net"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -31,6 +36,9 @@ type mockCachingProvider struct {
 
 	CachedToken       *TokenResult
 	CacheReturnsError error
+
+	ClearCalled       bool
+	ClearReturnsError error
 }
 
 func (i *mockCachingProvider) GetTokens() (*TokenResult, error) {
@@ -42,6 +50,11 @@ func (i *mockCachingProvider) CacheTokens(toCache *TokenResult) error {
 	return i.CacheReturnsError
 }
 
+func (i *mockCachingProvider) ClearTokens() error {
+	i.ClearCalled = true
+	return i.ClearReturnsError
+}
+
 type mockTokenProvider struct {
 	ReturnRefreshToken      *TokenResult
 	ReturnAuthenticateToken *TokenResult
@@ -72,6 +85,7 @@ var _ = Describe("CachingTokenProvider", func() {
 		ctp = CachingTokenProvider{
 			cache:               mockCache,
 			issuerTokenProvider: mockIssuerTokenProvider,
+			now:                 time.Now,
 		}
 	})
 
@@ -105,7 +119,8 @@ var _ = Describe("CachingTokenProvider", func() {
 		Expect(tokenResult).To(Equal(mockIssuerTokenProvider.ReturnRefreshToken))
 	})
 
-	It("runs a full authentication if refresh returns an error", func() {
+	It("runs a full authentication if refresh returns an error and the policy allows it", func() {
+		ctp.options.RefreshFailurePolicy = ReauthenticateOnAny
 		mockCache.ReturnToken = &TokenResult{
 			RefreshToken: "refreshToken",
 		}
@@ -151,14 +166,14 @@ var _ = Describe("CachingTokenProvider", func() {
 		Expect(mockCache.CachedToken).To(Equal(mockIssuerTokenProvider.ReturnAuthenticateToken))
 	})
 
-	It("caches the new access and id tokens and orig refresh token after refreshing", func() {
+	It("caches the new access, id and rotated refresh tokens after refreshing", func() {
 		mockCache.ReturnToken = &TokenResult{
 			RefreshToken: "refreshToken",
 		}
 		mockIssuerTokenProvider.ReturnRefreshToken = &TokenResult{
 			AccessToken:  genValidTokenWithExp(time.Now().Add(time.Minute * 2)),
 			IDToken:      genValidTokenWithExp(time.Now().Add(time.Minute * 2)),
-			RefreshToken: genValidTokenWithExp(time.Now().Add(time.Minute * 2)),
+			RefreshToken: "rotatedRefreshToken",
 		}
 
 		ctp.getTokenResult(func(tr TokenResult) bool { return false })
@@ -166,10 +181,167 @@ var _ = Describe("CachingTokenProvider", func() {
 		Expect(mockCache.CachedToken).To(Equal(&TokenResult{
 			AccessToken:  mockIssuerTokenProvider.ReturnRefreshToken.AccessToken,
 			IDToken:      mockIssuerTokenProvider.ReturnRefreshToken.IDToken,
-			RefreshToken: mockCache.ReturnToken.RefreshToken,
+			RefreshToken: "rotatedRefreshToken",
+		}))
+	})
+
+	It("keeps the orig refresh token when refreshing does not return one", func() {
+		mockCache.ReturnToken = &TokenResult{
+			RefreshToken: "refreshToken",
+		}
+		mockIssuerTokenProvider.ReturnRefreshToken = &TokenResult{
+			AccessToken: genValidTokenWithExp(time.Now().Add(time.Minute * 2)),
+		}
+
+		ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+		Expect(mockCache.CachedToken).To(Equal(&TokenResult{
+			AccessToken:  mockIssuerTokenProvider.ReturnRefreshToken.AccessToken,
+			RefreshToken: "refreshToken",
 		}))
 	})
 
+	It("clears the cache and authenticates when the refresh token is rejected", func() {
+		mockCache.ReturnToken = &TokenResult{
+			RefreshToken: "reusedRefreshToken",
+		}
+		mockIssuerTokenProvider.ReturnRefreshError = ErrInvalidGrant
+		mockIssuerTokenProvider.ReturnAuthenticateToken = &TokenResult{
+			AccessToken:  "accessToken",
+			RefreshToken: "newRefreshToken",
+		}
+
+		tokenResult, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockCache.ClearCalled).To(BeTrue())
+		Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeTrue())
+		Expect(tokenResult.RefreshToken).To(Equal("newRefreshToken"))
+		Expect(mockCache.CachedToken).To(Equal(tokenResult))
+	})
+
+	It("passes along an error from clearing the rejected tokens", func() {
+		mockCache.ReturnToken = &TokenResult{
+			RefreshToken: "reusedRefreshToken",
+		}
+		mockIssuerTokenProvider.ReturnRefreshError = ErrInvalidGrant
+		mockCache.ClearReturnsError = errors.New("uh oh")
+
+		tokenResult, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+		Expect(tokenResult).To(BeNil())
+		Expect(err.Error()).To(Equal("could not clear the rejected tokens from the cache: uh oh"))
+		Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeFalse())
+	})
+
+	It("does not clear the cache when refreshing fails for other reasons", func() {
+		ctp.options.RefreshFailurePolicy = ReauthenticateOnAny
+		mockCache.ReturnToken = &TokenResult{
+			RefreshToken: "refreshToken",
+		}
+		mockIssuerTokenProvider.ReturnRefreshError = errors.New("uh oh")
+
+		ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+		Expect(mockCache.ClearCalled).To(BeFalse())
+		Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeTrue())
+	})
+
+	Describe("refresh failures", func() {
+		var output *bytes.Buffer
+
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			ctp.options.Output = output
+			mockCache.ReturnToken = &TokenResult{
+				RefreshToken: "refreshToken",
+			}
+			mockIssuerTokenProvider.ReturnAuthenticateToken = &TokenResult{AccessToken: "accessToken"}
+		})
+
+		It("fails instead of authenticating when the issuer is unavailable", func() {
+			ctp.options.RefreshFailurePolicy = ReauthenticateOnNonTransient
+			mockIssuerTokenProvider.ReturnRefreshError = &OAuthError{StatusCode: 503}
+
+			tokenResult, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(tokenResult).To(BeNil())
+			Expect(err.Error()).To(Equal("could not refresh the tokens as the issuer is unavailable: A non-success status code was receveived: 503"))
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeFalse())
+			Expect(mockCache.ClearCalled).To(BeFalse())
+		})
+
+		It("fails by default when the issuer is unavailable", func() {
+			mockIssuerTokenProvider.ReturnRefreshError = &OAuthError{StatusCode: 502}
+
+			_, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(err).To(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeFalse())
+		})
+
+		It("authenticates again by default when the issuer responds with another error", func() {
+			mockIssuerTokenProvider.ReturnRefreshError = &OAuthError{Code: "invalid_client", Description: "unknown client", StatusCode: 400}
+
+			tokenResult, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokenResult.AccessToken).To(Equal("accessToken"))
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeTrue())
+			Expect(output.String()).To(Equal("Could not refresh the tokens as refreshing failed, logging in again: invalid_client: unknown client\n"))
+		})
+
+		It("fails by default when the jwks cannot be fetched to verify the refreshed id token", func() {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			idToken, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": "https://issuer"}).SignedString(key)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, transport := range []*mockTransport{
+				{Response: buildResponse(503, nil)},
+				{Error: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}},
+			} {
+				verifier := NewIDTokenVerifier("https://issuer", "clientID", NewRemoteKeySet("https://issuer/jwks", transport))
+				mockIssuerTokenProvider.ReturnRefreshError = verifier.Verify(idToken)
+
+				_, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+				Expect(err.Error()).To(HavePrefix("could not refresh the tokens as the issuer is unavailable: could not verify id token signature: could not get jwks from url https://issuer/jwks"))
+				Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeFalse())
+			}
+		})
+
+		It("fails for failures other than a rejected refresh token on invalid-grant", func() {
+			ctp.options.RefreshFailurePolicy = ReauthenticateOnInvalidGrant
+			mockIssuerTokenProvider.ReturnRefreshError = errors.New("invalid_client: unknown client")
+
+			_, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(err.Error()).To(Equal("could not refresh the tokens as refreshing failed: invalid_client: unknown client"))
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeFalse())
+		})
+
+		It("reports why it authenticates again", func() {
+			mockIssuerTokenProvider.ReturnRefreshError = ErrInvalidGrant
+
+			_, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeTrue())
+			Expect(output.String()).To(Equal("Could not refresh the tokens as the refresh token was rejected, logging in again: invalid_grant\n"))
+		})
+
+		It("authenticates without reporting when refreshing is not supported", func() {
+			mockIssuerTokenProvider.ReturnRefreshError = refreshNotSupportedError("refresh tokens are not supported")
+
+			_, err := ctp.getTokenResult(func(tr TokenResult) bool { return false })
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledAuthenticate).To(BeTrue())
+			Expect(output.String()).To(BeEmpty())
+		})
+	})
+
 	It("passes along an error from authenticate", func() {
 		mockIssuerTokenProvider.ReturnAuthenticateError = errors.New("someerror")
 
@@ -253,4 +425,159 @@ var _ = Describe("CachingTokenProvider", func() {
 		})
 	})
 
+	Describe("GetAccessTokenWithExpiry", func() {
+		It("uses the exp claim of JWT access tokens", func() {
+			exp := time.Now().Add(time.Hour).Truncate(time.Second)
+			mockIssuerTokenProvider.ReturnAuthenticateToken = &TokenResult{
+				AccessToken: genValidTokenWithExp(exp),
+				ExpiresIn:   60,
+			}
+
+			_, expiry, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiry.Equal(exp)).To(BeTrue())
+		})
+
+		It("uses expires_in for opaque access tokens and caches the expiry", func() {
+			mockIssuerTokenProvider.ReturnAuthenticateToken = &TokenResult{
+				AccessToken: "opaque",
+				ExpiresIn:   3600,
+			}
+
+			accessToken, expiry, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accessToken).To(Equal("opaque"))
+			Expect(expiry).To(BeTemporally("~", time.Now().Add(time.Hour), 2*time.Second))
+			Expect(mockCache.CachedToken.ExpiresAt).To(Equal(expiry.Unix()))
+		})
+
+		It("hands out cached opaque access tokens until expires_at", func() {
+			now := time.Now().Truncate(time.Second)
+			ctp.now = func() time.Time { return now }
+			mockCache.ReturnToken = &TokenResult{
+				AccessToken:  "opaque",
+				ExpiresAt:    now.Add(time.Hour).Unix(),
+				RefreshToken: "rt",
+			}
+			mockIssuerTokenProvider.ReturnRefreshToken = &TokenResult{AccessToken: "refreshed", ExpiresIn: 3600}
+
+			accessToken, _, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accessToken).To(Equal("opaque"))
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(BeEmpty())
+
+			now = now.Add(time.Hour + time.Second)
+			accessToken, _, err = ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accessToken).To(Equal("refreshed"))
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(Equal("rt"))
+			Expect(mockCache.CachedToken.ExpiresAt).To(Equal(now.Add(time.Hour).Unix()))
+		})
+
+		It("returns the zero time when the expiry is not known", func() {
+			mockIssuerTokenProvider.ReturnAuthenticateToken = &TokenResult{AccessToken: "opaque"}
+
+			_, expiry, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiry.IsZero()).To(BeTrue())
+		})
+	})
+
+	Describe("refresh ahead and clock skew", func() {
+		var now time.Time
+
+		BeforeEach(func() {
+			now = time.Now().Truncate(time.Second)
+			ctp.now = func() time.Time { return now }
+			mockCache.ReturnToken = &TokenResult{
+				AccessToken:  genValidTokenWithExp(now.Add(30 * time.Second)),
+				RefreshToken: "rt",
+			}
+			mockIssuerTokenProvider.ReturnRefreshToken = &TokenResult{
+				AccessToken: genValidTokenWithExp(now.Add(time.Hour)),
+			}
+		})
+
+		It("hands out tokens until they expire by default", func() {
+			accessToken, _, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accessToken).To(Equal(mockCache.ReturnToken.AccessToken))
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(BeEmpty())
+		})
+
+		It("refreshes tokens that expire within the refresh ahead window", func() {
+			ctp.options.RefreshAhead = time.Minute
+
+			accessToken, _, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accessToken).To(Equal(mockIssuerTokenProvider.ReturnRefreshToken.AccessToken))
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(Equal("rt"))
+		})
+
+		It("refreshes tokens that expire within the clock skew allowance", func() {
+			ctp.options.ClockSkew = 45 * time.Second
+
+			_, _, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(Equal("rt"))
+		})
+
+		It("brings the reported expiry forward so kubectl comes back before the token expires", func() {
+			ctp.options.RefreshAhead = time.Minute
+			ctp.options.ClockSkew = 10 * time.Second
+
+			_, expiry, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiry).To(Equal(now.Add(time.Hour - 70*time.Second)))
+		})
+
+		It("reports the actual expiry of tokens that live shorter than the window", func() {
+			ctp.options.RefreshAhead = time.Minute
+			mockIssuerTokenProvider.ReturnRefreshToken.AccessToken = genValidTokenWithExp(now.Add(30 * time.Second))
+
+			_, expiry, err := ctp.GetAccessTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiry).To(Equal(now.Add(30 * time.Second)))
+		})
+
+		It("corrects the expiry for the detected clock skew when asked to", func() {
+			mockCache.ReturnToken.ClockSkew = 40
+
+			_, _, err := ctp.GetAccessTokenWithExpiry()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(BeEmpty())
+
+			ctp.options.DetectClockSkew = true
+			mockIssuerTokenProvider.ReturnRefreshToken.ClockSkew = 40
+
+			_, expiry, err := ctp.GetAccessTokenWithExpiry()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockIssuerTokenProvider.CalledWithRefreshToken).To(Equal("rt"))
+			Expect(expiry).To(Equal(now.Add(time.Hour - 40*time.Second)))
+		})
+	})
+
+	Describe("GetIDTokenWithExpiry", func() {
+		It("uses the exp claim of the id token", func() {
+			exp := time.Now().Add(time.Hour).Truncate(time.Second)
+			mockCache.ReturnToken = &TokenResult{IDToken: genValidTokenWithExp(exp)}
+
+			idToken, expiry, err := ctp.GetIDTokenWithExpiry()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idToken).To(Equal(mockCache.ReturnToken.IDToken))
+			Expect(expiry.Equal(exp)).To(BeTrue())
+		})
+	})
+
 })
@@ -0,0 +1,47 @@
+package auth
+
+// CertificateBoundCachingProvider satisfies the cachingProvider interface and
+// wraps another cachingProvider in order to bind the cached tokens to a
+// client certificate. Tokens that were cached while a different certificate
+// was in use are ignored so that a new authentication is forced.
+type CertificateBoundCachingProvider struct {
+	cache      cachingProvider
+	thumbprint string
+}
+
+// NewCertificateBoundCachingProvider builds a new
+// CertificateBoundCachingProvider that binds the tokens in <cache> to the
+// certificate with the passed in thumbprint
+func NewCertificateBoundCachingProvider(cache cachingProvider, thumbprint string) *CertificateBoundCachingProvider {
+	return &CertificateBoundCachingProvider{
+		cache:      cache,
+		thumbprint: thumbprint,
+	}
+}
+
+// GetTokens gets the TokenResult from the wrapped cache. No TokenResult is
+// returned when the cached tokens are bound to a different certificate.
+func (c *CertificateBoundCachingProvider) GetTokens() (*TokenResult, error) {
+	tokenResult, err := c.cache.GetTokens()
+	if err != nil || tokenResult == nil {
+		return tokenResult, err
+	}
+
+	if tokenResult.CertificateThumbprint != c.thumbprint {
+		return nil, nil
+	}
+
+	return tokenResult, nil
+}
+
+// CacheTokens records the certificate thumbprint with the TokenResult and
+// stores it in the wrapped cache
+func (c *CertificateBoundCachingProvider) CacheTokens(tr *TokenResult) error {
+	tr.CertificateThumbprint = c.thumbprint
+	return c.cache.CacheTokens(tr)
+}
+
+// ClearTokens removes the TokenResult from the wrapped cache
+func (c *CertificateBoundCachingProvider) ClearTokens() error {
+	return c.cache.ClearTokens()
+}
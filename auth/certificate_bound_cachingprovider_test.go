@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CertificateBoundCachingProvider", func() {
+	var cache *mockCachingProvider
+	var provider *CertificateBoundCachingProvider
+
+	BeforeEach(func() {
+		cache = &mockCachingProvider{}
+		provider = NewCertificateBoundCachingProvider(cache, "thumbprint")
+	})
+
+	It("returns tokens bound to the same certificate", func() {
+		cache.ReturnToken = &TokenResult{AccessToken: "access", CertificateThumbprint: "thumbprint"}
+
+		tokenResult, err := provider.GetTokens()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenResult).To(Equal(cache.ReturnToken))
+	})
+
+	It("ignores tokens bound to a different certificate", func() {
+		cache.ReturnToken = &TokenResult{AccessToken: "access", CertificateThumbprint: "other"}
+
+		tokenResult, err := provider.GetTokens()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenResult).To(BeNil())
+	})
+
+	It("ignores tokens that are not bound to a certificate", func() {
+		cache.ReturnToken = &TokenResult{AccessToken: "access"}
+
+		tokenResult, err := provider.GetTokens()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenResult).To(BeNil())
+	})
+
+	It("passes along errors from the wrapped cache", func() {
+		cache.GetReturnsError = errors.New("uh oh")
+
+		tokenResult, err := provider.GetTokens()
+
+		Expect(tokenResult).To(BeNil())
+		Expect(err.Error()).To(Equal("uh oh"))
+	})
+
+	It("records the thumbprint when caching tokens", func() {
+		err := provider.CacheTokens(&TokenResult{AccessToken: "access"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.CachedToken).To(Equal(&TokenResult{AccessToken: "access", CertificateThumbprint: "thumbprint"}))
+	})
+
+	It("clears the tokens in the wrapped cache", func() {
+		cache.ClearReturnsError = errors.New("uh oh")
+
+		err := provider.ClearTokens()
+
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(err.Error()).To(Equal("uh oh"))
+	})
+})
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// Client authentication methods that can be used at the token endpoint as
+// registered by OpenID Connect Core and RFC 7591
+const (
+	ClientAuthMethodNone          = "none"
+	ClientAuthMethodSecretBasic   = "client_secret_basic"
+	ClientAuthMethodSecretPost    = "client_secret_post"
+	ClientAuthMethodSecretJWT     = "client_secret_jwt"
+	ClientAuthMethodPrivateKeyJWT = "private_key_jwt"
+
+	ClientAuthMethodTLSClientAuth           = "tls_client_auth"
+	ClientAuthMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// clientAssertionType is the client_assertion_type sent with JWT client
+// authentication as defined by RFC 7523
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long a client assertion is valid for
+const clientAssertionLifetime = 5 * time.Minute
+
+// ClientAuthenticator abstracts adding client authentication to a request
+// that is sent to <endpoint>. Authentication is added to the form <params>
+// or the request <header>.
+type ClientAuthenticator interface {
+	AuthenticateClient(endpoint string, params url.Values, header http.Header) error
+}
+
+// clientSecretBasic authenticates using HTTP basic authentication
+type clientSecretBasic struct {
+	clientID string
+	secret   SecretReader
+}
+
+func (a *clientSecretBasic) AuthenticateClient(endpoint string, params url.Values, header http.Header) error {
+	secret, err := a.secret()
+	if err != nil {
+		return errors.Wrap(err, "could not read client secret")
+	}
+
+	// RFC 6749 section 2.3.1 requires the credentials to be form encoded
+	// before being base64 encoded
+	credentials := url.QueryEscape(a.clientID) + ":" + url.QueryEscape(secret)
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+
+	return nil
+}
+
+// clientSecretPost authenticates by sending the client secret in the form
+// body
+type clientSecretPost struct {
+	clientID string
+	secret   SecretReader
+}
+
+func (a *clientSecretPost) AuthenticateClient(endpoint string, params url.Values, header http.Header) error {
+	secret, err := a.secret()
+	if err != nil {
+		return errors.Wrap(err, "could not read client secret")
+	}
+
+	params.Set("client_id", a.clientID)
+	params.Set("client_secret", secret)
+
+	return nil
+}
+
+// clientAssertionJWT authenticates by sending a JWT signed with either the
+// client secret or the client private key
+type clientAssertionJWT struct {
+	clientID string
+	// signingKey returns the signing method and key the assertion is signed
+	// with
+	signingKey func() (jwt.SigningMethod, interface{}, error)
+}
+
+func (a *clientAssertionJWT) AuthenticateClient(endpoint string, params url.Values, header http.Header) error {
+	method, key, err := a.signingKey()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(method, jwt.StandardClaims{
+		Issuer:    a.clientID,
+		Subject:   a.clientID,
+		Audience:  endpoint,
+		Id:        generateRandomString(16),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(clientAssertionLifetime).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return errors.Wrap(err, "could not sign client assertion")
+	}
+
+	params.Set("client_id", a.clientID)
+	params.Set("client_assertion_type", clientAssertionType)
+	params.Set("client_assertion", assertion)
+
+	return nil
+}
+
+// secretSigningKey builds the signingKey func for client_secret_jwt
+func secretSigningKey(secret SecretReader) func() (jwt.SigningMethod, interface{}, error) {
+	return func() (jwt.SigningMethod, interface{}, error) {
+		s, err := secret()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not read client secret")
+		}
+
+		return jwt.SigningMethodHS256, []byte(s), nil
+	}
+}
+
+// privateKeySigningKey builds the signingKey func for private_key_jwt. The
+// PEM encoded key can either be an RSA or an EC P-256 key.
+func privateKeySigningKey(key SecretReader) func() (jwt.SigningMethod, interface{}, error) {
+	return func() (jwt.SigningMethod, interface{}, error) {
+		pemKey, err := key()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not read client private key")
+		}
+
+		if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemKey)); err == nil {
+			return jwt.SigningMethodRS256, rsaKey, nil
+		}
+
+		ecKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(pemKey))
+		if err != nil {
+			return nil, nil, errors.New("client private key is not a PEM encoded RSA or EC private key")
+		}
+
+		return jwt.SigningMethodES256, ecKey, nil
+	}
+}
+
+// NewClientAuthenticator builds the ClientAuthenticator for <method>. A nil
+// ClientAuthenticator is returned for public clients using the none method
+// and for the mutual TLS methods as the client is authenticated by the
+// certificate presented during the TLS handshake.
+func NewClientAuthenticator(method, clientID string, secret, key SecretReader) (ClientAuthenticator, error) {
+	requireSecret := func() error {
+		if secret == nil {
+			return errors.Errorf("a client secret is required for the %s client authentication method", method)
+		}
+		return nil
+	}
+
+	switch method {
+	case ClientAuthMethodNone, ClientAuthMethodTLSClientAuth, ClientAuthMethodSelfSignedTLSClientAuth:
+		return nil, nil
+	case ClientAuthMethodSecretBasic:
+		if err := requireSecret(); err != nil {
+			return nil, err
+		}
+		return &clientSecretBasic{clientID: clientID, secret: secret}, nil
+	case ClientAuthMethodSecretPost:
+		if err := requireSecret(); err != nil {
+			return nil, err
+		}
+		return &clientSecretPost{clientID: clientID, secret: secret}, nil
+	case ClientAuthMethodSecretJWT:
+		if err := requireSecret(); err != nil {
+			return nil, err
+		}
+		return &clientAssertionJWT{clientID: clientID, signingKey: secretSigningKey(secret)}, nil
+	case ClientAuthMethodPrivateKeyJWT:
+		if key == nil {
+			return nil, errors.Errorf("a client private key is required for the %s client authentication method", method)
+		}
+		return &clientAssertionJWT{clientID: clientID, signingKey: privateKeySigningKey(key)}, nil
+	}
+
+	return nil, errors.Errorf("unknown client authentication method %q", method)
+}
+
+// SelectClientAuthMethod picks the client authentication method to use based
+// on the methods the issuer supports and the credentials that are available.
+// A client private key is preferred over a client secret. When the issuer
+// does not advertise any methods client_secret_basic is assumed as per
+// OpenID Connect Discovery.
+func SelectClientAuthMethod(supported []string, hasSecret, hasKey bool) (string, error) {
+	if !hasSecret && !hasKey {
+		return ClientAuthMethodNone, nil
+	}
+
+	if len(supported) == 0 {
+		supported = []string{ClientAuthMethodSecretBasic}
+	}
+
+	isSupported := map[string]bool{}
+	for _, method := range supported {
+		isSupported[method] = true
+	}
+
+	var preferred []string
+	if hasKey {
+		preferred = append(preferred, ClientAuthMethodPrivateKeyJWT)
+	}
+	if hasSecret {
+		preferred = append(preferred, ClientAuthMethodSecretBasic, ClientAuthMethodSecretPost, ClientAuthMethodSecretJWT)
+	}
+
+	for _, method := range preferred {
+		if isSupported[method] {
+			return method, nil
+		}
+	}
+
+	return "", errors.Errorf("none of the issuer's supported client authentication methods %v can be used with the configured client credentials", supported)
+}
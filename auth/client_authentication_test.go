@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/url"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func staticSecret(secret string) SecretReader {
+	return func() (string, error) { return secret, nil }
+}
+
+var _ = Describe("ClientAuthenticator", func() {
+	const endpoint = "https://issuer/oauth/token"
+
+	var params url.Values
+	var header http.Header
+
+	BeforeEach(func() {
+		params = url.Values{"client_id": []string{"clientID"}}
+		header = http.Header{}
+	})
+
+	It("uses form encoded credentials for client_secret_basic", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodSecretBasic, "client:ID", staticSecret("sec ret"), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.AuthenticateClient(endpoint, params, header)).To(Succeed())
+
+		r := &http.Request{Header: header}
+		username, password, ok := r.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(username).To(Equal("client%3AID"))
+		Expect(password).To(Equal("sec+ret"))
+		Expect(params.Get("client_secret")).To(BeEmpty())
+	})
+
+	It("sends the secret in the body for client_secret_post", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodSecretPost, "clientID", staticSecret("secret"), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.AuthenticateClient(endpoint, params, header)).To(Succeed())
+
+		Expect(params.Get("client_id")).To(Equal("clientID"))
+		Expect(params.Get("client_secret")).To(Equal("secret"))
+		Expect(header).To(BeEmpty())
+	})
+
+	It("sends an HMAC signed assertion for client_secret_jwt", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodSecretJWT, "clientID", staticSecret("secret"), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.AuthenticateClient(endpoint, params, header)).To(Succeed())
+
+		Expect(params.Get("client_assertion_type")).To(Equal("urn:ietf:params:oauth:client-assertion-type:jwt-bearer"))
+		claims := jwt.StandardClaims{}
+		_, err = jwt.ParseWithClaims(params.Get("client_assertion"), &claims, func(t *jwt.Token) (interface{}, error) {
+			Expect(t.Method).To(Equal(jwt.SigningMethodHS256))
+			return []byte("secret"), nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.Issuer).To(Equal("clientID"))
+		Expect(claims.Subject).To(Equal("clientID"))
+		Expect(claims.Audience).To(Equal(endpoint))
+		Expect(claims.Id).NotTo(BeEmpty())
+	})
+
+	It("sends a private key signed assertion for private_key_jwt", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		der, err := x509.MarshalECPrivateKey(key)
+		Expect(err).NotTo(HaveOccurred())
+		pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+
+		a, err := NewClientAuthenticator(ClientAuthMethodPrivateKeyJWT, "clientID", nil, staticSecret(pemKey))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.AuthenticateClient(endpoint, params, header)).To(Succeed())
+
+		_, err = jwt.Parse(params.Get("client_assertion"), func(t *jwt.Token) (interface{}, error) {
+			Expect(t.Method).To(Equal(jwt.SigningMethodES256))
+			return &key.PublicKey, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("errors when the private key cannot be parsed", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodPrivateKeyJWT, "clientID", nil, staticSecret("not a key"))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = a.AuthenticateClient(endpoint, params, header)
+
+		Expect(err.Error()).To(Equal("client private key is not a PEM encoded RSA or EC private key"))
+	})
+
+	It("errors when reading the secret fails", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodSecretBasic, "clientID", func() (string, error) { return "", errors.New("uh oh") }, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = a.AuthenticateClient(endpoint, params, header)
+
+		Expect(err.Error()).To(Equal("could not read client secret: uh oh"))
+	})
+
+	It("returns no authenticator for public clients", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodNone, "clientID", nil, nil)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a).To(BeNil())
+	})
+
+	It("errors when the needed credentials are missing", func() {
+		_, err := NewClientAuthenticator(ClientAuthMethodSecretPost, "clientID", nil, nil)
+		Expect(err.Error()).To(Equal("a client secret is required for the client_secret_post client authentication method"))
+
+		_, err = NewClientAuthenticator(ClientAuthMethodPrivateKeyJWT, "clientID", staticSecret("secret"), nil)
+		Expect(err.Error()).To(Equal("a client private key is required for the private_key_jwt client authentication method"))
+	})
+
+	It("errors for unknown methods", func() {
+		_, err := NewClientAuthenticator("client_secret_magic", "clientID", nil, nil)
+
+		Expect(err.Error()).To(Equal(`unknown client authentication method "client_secret_magic"`))
+	})
+
+	It("returns no authenticator for the mutual TLS methods", func() {
+		a, err := NewClientAuthenticator(ClientAuthMethodTLSClientAuth, "clientID", nil, nil)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a).To(BeNil())
+	})
+
+	Describe("SelectClientAuthMethod", func() {
+		It("uses none when there are no credentials", func() {
+			Expect(SelectClientAuthMethod([]string{ClientAuthMethodSecretBasic}, false, false)).To(Equal(ClientAuthMethodNone))
+		})
+
+		It("assumes client_secret_basic when nothing is advertised", func() {
+			Expect(SelectClientAuthMethod(nil, true, false)).To(Equal(ClientAuthMethodSecretBasic))
+		})
+
+		It("prefers a private key when supported", func() {
+			Expect(SelectClientAuthMethod([]string{ClientAuthMethodSecretPost, ClientAuthMethodPrivateKeyJWT}, true, true)).To(Equal(ClientAuthMethodPrivateKeyJWT))
+		})
+
+		It("picks the first supported secret method", func() {
+			Expect(SelectClientAuthMethod([]string{ClientAuthMethodSecretJWT, ClientAuthMethodSecretPost}, true, false)).To(Equal(ClientAuthMethodSecretPost))
+		})
+
+		It("errors when no supported method can be used", func() {
+			_, err := SelectClientAuthMethod([]string{ClientAuthMethodPrivateKeyJWT}, true, false)
+
+			Expect(err.Error()).To(Equal("none of the issuer's supported client authentication methods [private_key_jwt] can be used with the configured client credentials"))
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package auth
+
+import "github.com/pkg/errors"
+
+// ClientCredentialsExchanger abstracts exchanging client credentials for
+// tokens
+type ClientCredentialsExchanger interface {
+	ExchangeClientCredentials(req ClientCredentialsExchangeRequest) (*TokenResult, error)
+}
+
+// ClientCredentialsTokenProvider satisfies the IssuerTokenProvider interface
+// and gets tokens without any user interaction using the client credentials
+// grant. It is meant for CI pipelines and bots.
+type ClientCredentialsTokenProvider struct {
+	issuerData Issuer
+	exchanger  ClientCredentialsExchanger
+}
+
+// NewClientCredentialsTokenProvider allows for the easy setup of
+// ClientCredentialsTokenProvider. The <exchanger> is responsible for
+// authenticating the client.
+func NewClientCredentialsTokenProvider(
+	issuerData Issuer,
+	exchanger ClientCredentialsExchanger) *ClientCredentialsTokenProvider {
+	return &ClientCredentialsTokenProvider{
+		issuerData: issuerData,
+		exchanger:  exchanger,
+	}
+}
+
+// NewDefaultClientCredentialsTokenProvider provides an easy way to build up
+// a default client credentials token provider with all the correct
+// configuration. <options> must hold a client secret or key, or a client
+// certificate when the client authenticates using mutual TLS.
+func NewDefaultClientCredentialsTokenProvider(issuerData Issuer, options ClientOptions) (*ClientCredentialsTokenProvider, error) {
+	if options.ClientSecret == nil && options.ClientKey == nil && !authenticatesWithCertificate(options) {
+		return nil, errors.New("a client secret or key, or a client certificate with a tls_client_auth method, is required for the client credentials grant")
+	}
+
+	tokenRetriever, _, err := newDefaultTokenRetriever(issuerData, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClientCredentialsTokenProvider(
+		issuerData,
+		tokenRetriever), nil
+}
+
+// Authenticate is used to retrieve a TokenResult using the client credentials
+func (p *ClientCredentialsTokenProvider) Authenticate() (*TokenResult, error) {
+	tokenResult, err := p.exchanger.ExchangeClientCredentials(ClientCredentialsExchangeRequest{
+		ClientID: p.issuerData.ClientID,
+		Audience: p.issuerData.Audience,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not exchange client credentials")
+	}
+
+	return tokenResult, nil
+}
+
+// FromRefreshToken always returns an error as refresh tokens are not issued
+// for the client credentials grant
+func (p *ClientCredentialsTokenProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
+	return nil, refreshNotSupportedError("refresh tokens are not supported with the client credentials grant")
+}
+
+// authenticatesWithCertificate checks if the client is authenticated by the
+// certificate it presents as defined by RFC 8705
+func authenticatesWithCertificate(options ClientOptions) bool {
+	switch options.ClientAuthMethod {
+	case ClientAuthMethodTLSClientAuth, ClientAuthMethodSelfSignedTLSClientAuth:
+		return options.ClientCertificate != nil
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockClientCredentialsExchanger struct {
+	CalledWithRequest *ClientCredentialsExchangeRequest
+	ReturnsTokens     *TokenResult
+	ReturnsError      error
+}
+
+func (m *mockClientCredentialsExchanger) ExchangeClientCredentials(req ClientCredentialsExchangeRequest) (*TokenResult, error) {
+	m.CalledWithRequest = &req
+	return m.ReturnsTokens, m.ReturnsError
+}
+
+var _ = Describe("ClientCredentialsTokenProvider", func() {
+	issuer := Issuer{
+		IssuerEndpoint: "http://issuer",
+		ClientID:       "test_clientID",
+		Audience:       "test_audience",
+	}
+
+	var exchanger *mockClientCredentialsExchanger
+	var provider *ClientCredentialsTokenProvider
+
+	BeforeEach(func() {
+		exchanger = &mockClientCredentialsExchanger{
+			ReturnsTokens: &TokenResult{AccessToken: "accessToken", ExpiresIn: 1234},
+		}
+		provider = NewClientCredentialsTokenProvider(issuer, exchanger)
+	})
+
+	It("exchanges the client credentials for tokens", func() {
+		tokens, err := provider.Authenticate()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens).To(Equal(exchanger.ReturnsTokens))
+		Expect(exchanger.CalledWithRequest).To(Equal(&ClientCredentialsExchangeRequest{
+			ClientID: "test_clientID",
+			Audience: "test_audience",
+		}))
+	})
+
+	It("returns an error when the exchange fails", func() {
+		exchanger.ReturnsError = errors.New("someerror")
+
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not exchange client credentials: someerror"))
+	})
+
+	It("requires a client secret or key", func() {
+		p, err := NewDefaultClientCredentialsTokenProvider(issuer, ClientOptions{})
+
+		Expect(p).To(BeNil())
+		Expect(err.Error()).To(Equal("a client secret or key, or a client certificate with a tls_client_auth method, is required for the client credentials grant"))
+
+		p, err = NewDefaultClientCredentialsTokenProvider(issuer, ClientOptions{ClientCertificate: &tls.Certificate{}})
+
+		Expect(p).To(BeNil())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("accepts a client certificate when the client authenticates using mutual TLS", func() {
+		for _, method := range []string{ClientAuthMethodTLSClientAuth, ClientAuthMethodSelfSignedTLSClientAuth} {
+			p, err := NewDefaultClientCredentialsTokenProvider(issuer, ClientOptions{
+				ClientAuthMethod:  method,
+				ClientCertificate: &tls.Certificate{},
+				Endpoints:         &OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/token"},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p).NotTo(BeNil())
+		}
+	})
+
+	It("does not support refresh tokens", func() {
+		tokens, err := provider.FromRefreshToken("refresh")
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("refresh tokens are not supported with the client credentials grant"))
+	})
+})
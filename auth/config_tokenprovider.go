@@ -38,3 +38,9 @@ func (c *ConfigBackedCachingProvider) CacheTokens(toCache *TokenResult) error {
 	c.config.SaveTokens(c.identifier, toCache.AccessToken, toCache.RefreshToken)
 	return nil
 }
+
+// ClearTokens removes the tokens from the configProvider
+func (c *ConfigBackedCachingProvider) ClearTokens() error {
+	c.config.SaveTokens(c.identifier, "", "")
+	return nil
+}
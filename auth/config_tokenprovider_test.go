@@ -70,5 +70,20 @@ var _ = Describe("main", func() {
 			Expect(c.SavedAccessToken).To(Equal(toSave.AccessToken))
 			Expect(c.SavedRefreshToken).To(Equal(toSave.RefreshToken))
 		})
+
+		It("clears the tokens in the config provider", func() {
+			c := &mockConfigProvider{SavedAccessToken: "accessToken", SavedRefreshToken: "refreshToken"}
+			p := ConfigBackedCachingProvider{
+				identifier: "iamidentifier",
+				config:     c,
+			}
+
+			err := p.ClearTokens()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.SavedIdentifier).To(Equal(p.identifier))
+			Expect(c.SavedAccessToken).To(BeEmpty())
+			Expect(c.SavedRefreshToken).To(BeEmpty())
+		})
 	})
 })
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/99designs/keyring"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// TokenTypeDPoP is the token_type returned by the token endpoint for DPoP
+// bound tokens
+const TokenTypeDPoP = "DPoP"
+
+// DPoPProver creates the DPoP proofs that are sent in the DPoP header of
+// requests to the issuer as defined by RFC 9449
+type DPoPProver struct {
+	key *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	nonce string
+}
+
+// NewDPoPProver builds a DPoPProver that signs proofs with <key>
+func NewDPoPProver(key *ecdsa.PrivateKey) *DPoPProver {
+	return &DPoPProver{key: key}
+}
+
+// Proof builds a DPoP proof for a request with <method> to <target>
+func (p *DPoPProver) Proof(method, target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse DPoP target url")
+	}
+	// htu must not include the query and fragment parts
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	claims := jwt.MapClaims{
+		"jti": generateRandomString(16),
+		"htm": method,
+		"htu": u.String(),
+		"iat": time.Now().Unix(),
+	}
+	if nonce := p.Nonce(); nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = publicJWK(&p.key.PublicKey)
+
+	proof, err := token.SignedString(p.key)
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign DPoP proof")
+	}
+
+	return proof, nil
+}
+
+// Nonce returns the last nonce the issuer provided
+func (p *DPoPProver) Nonce() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.nonce
+}
+
+// SetNonce stores the nonce the issuer provided so that it is included in
+// future proofs
+func (p *DPoPProver) SetNonce(nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nonce = nonce
+}
+
+// publicJWK builds the JWK representation of an EC P-256 public key
+func publicJWK(key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(padBytes(key.X.Bytes(), size)),
+		"y":   base64.RawURLEncoding.EncodeToString(padBytes(key.Y.Bytes(), size)),
+	}
+}
+
+// padBytes left pads <b> with zeros to <size> bytes
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// LoadOrCreateDPoPKey gets the DPoP key pair for the client from the keyring
+// and generates and stores a new one if none exists yet
+func LoadOrCreateDPoPKey(krp KeyringProvider, clientID string) (*ecdsa.PrivateKey, error) {
+	identifier := "dpop-" + clientID
+
+	item, err := krp.Get(identifier)
+	if err == nil {
+		key, err := x509.ParseECPrivateKey(item.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse DPoP key from keyring")
+		}
+
+		return key, nil
+	}
+
+	if err != keyring.ErrKeyNotFound {
+		return nil, errors.Wrap(err, "error getting DPoP key from keyring")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate DPoP key")
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal DPoP key")
+	}
+
+	err = krp.Set(keyring.Item{
+		Key:  identifier,
+		Data: der,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error setting DPoP key in keyring")
+	}
+
+	return key, nil
+}
+
+// RemoveDPoPKey removes the DPoP key pair of the client from the keyring. It
+// is not an error when there is no key.
+func RemoveDPoPKey(krp KeyringProvider, clientID string) error {
+	err := krp.Remove("dpop-" + clientID)
+	if err != nil && err != keyring.ErrKeyNotFound {
+		return errors.Wrap(err, "error removing DPoP key from keyring")
+	}
+
+	return nil
+}
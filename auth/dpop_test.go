@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"github.com/99designs/keyring"
+	jwt "github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DPoPProver", func() {
+	var key *ecdsa.PrivateKey
+
+	BeforeEach(func() {
+		var err error
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("builds a proof signed by the key embedded in its header", func() {
+		p := NewDPoPProver(key)
+
+		proof, err := p.Proof("POST", "https://issuer/oauth/token?a=b#c")
+		Expect(err).NotTo(HaveOccurred())
+
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(proof, claims, func(t *jwt.Token) (interface{}, error) {
+			jwk := t.Header["jwk"].(map[string]interface{})
+			x, _ := base64.RawURLEncoding.DecodeString(jwk["x"].(string))
+			y, _ := base64.RawURLEncoding.DecodeString(jwk["y"].(string))
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(token.Method).To(Equal(jwt.SigningMethodES256))
+		Expect(token.Header["typ"]).To(Equal("dpop+jwt"))
+		Expect(claims["htm"]).To(Equal("POST"))
+		Expect(claims["htu"]).To(Equal("https://issuer/oauth/token"))
+		Expect(claims["jti"]).NotTo(BeEmpty())
+		Expect(claims["iat"]).NotTo(BeNil())
+		Expect(claims).NotTo(HaveKey("nonce"))
+	})
+
+	It("includes the nonce once one is set", func() {
+		p := NewDPoPProver(key)
+		p.SetNonce("nonce")
+
+		proof, err := p.Proof("POST", "https://issuer/oauth/token")
+		Expect(err).NotTo(HaveOccurred())
+
+		claims := jwt.MapClaims{}
+		_, err = jwt.ParseWithClaims(proof, claims, func(t *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims["nonce"]).To(Equal("nonce"))
+	})
+
+	Describe("LoadOrCreateDPoPKey", func() {
+		It("loads the key from the keyring", func() {
+			der, err := x509.MarshalECPrivateKey(key)
+			Expect(err).NotTo(HaveOccurred())
+			krp := &mockKeyringProvider{GetReturnsItem: keyring.Item{Data: der}}
+
+			loaded, err := LoadOrCreateDPoPKey(krp, "clientID")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(krp.GetCalledWith).To(Equal("dpop-clientID"))
+			Expect(loaded.D).To(Equal(key.D))
+		})
+
+		It("creates and stores a key when none exists", func() {
+			krp := &mockKeyringProvider{GetReturnsError: keyring.ErrKeyNotFound}
+
+			created, err := LoadOrCreateDPoPKey(krp, "clientID")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(krp.SetCalledWith.Key).To(Equal("dpop-clientID"))
+			stored, err := x509.ParseECPrivateKey(krp.SetCalledWith.Data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.D).To(Equal(created.D))
+		})
+
+		It("errors when the keyring errors", func() {
+			krp := &mockKeyringProvider{GetReturnsError: errors.New("uh oh")}
+
+			_, err := LoadOrCreateDPoPKey(krp, "clientID")
+
+			Expect(err.Error()).To(Equal("error getting DPoP key from keyring: uh oh"))
+		})
+
+		It("errors when the stored key cannot be parsed", func() {
+			krp := &mockKeyringProvider{GetReturnsItem: keyring.Item{Data: []byte("bad")}}
+
+			_, err := LoadOrCreateDPoPKey(krp, "clientID")
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("RemoveDPoPKey", func() {
+		It("removes the key from the keyring", func() {
+			krp := &mockKeyringProvider{}
+
+			Expect(RemoveDPoPKey(krp, "clientID")).To(Succeed())
+			Expect(krp.RemoveCalledWith).To(Equal("dpop-clientID"))
+		})
+
+		It("does not error when there is no key", func() {
+			krp := &mockKeyringProvider{RemoveReturnsError: keyring.ErrKeyNotFound}
+
+			Expect(RemoveDPoPKey(krp, "clientID")).To(Succeed())
+		})
+
+		It("errors when the keyring errors", func() {
+			krp := &mockKeyringProvider{RemoveReturnsError: errors.New("uh oh")}
+
+			Expect(RemoveDPoPKey(krp, "clientID")).To(MatchError("error removing DPoP key from keyring: uh oh"))
+		})
+	})
+})
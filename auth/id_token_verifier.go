@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// idTokenClockSkew is how far the clocks of the issuer and this machine are
+// allowed to be apart when checking the time based claims unless a larger
+// allowance is configured
+const idTokenClockSkew = time.Minute
+
+// KeySet abstracts getting the public key an issuer signed a token with
+type KeySet interface {
+	GetKey(kid string) (interface{}, error)
+}
+
+// IDTokenVerifier verifies the signature and claims of ID tokens as required
+// by OpenID Connect Core 1.0 section 3.1.3.7
+type IDTokenVerifier struct {
+	issuer   string
+	clientID string
+	keySet   KeySet
+	// clockSkew is how far the clocks are allowed to be apart when it is
+	// larger than idTokenClockSkew
+	clockSkew time.Duration
+	// detectClockSkew checks the time based claims against the issuer's clock
+	// as seen in the Date header of the token response
+	detectClockSkew bool
+	// now allows us to control the current time in tests
+	now func() time.Time
+}
+
+// NewIDTokenVerifier builds an IDTokenVerifier that expects tokens issued by
+// <issuer> for <clientID> that are signed by a key in <keySet>
+func NewIDTokenVerifier(issuer, clientID string, keySet KeySet) *IDTokenVerifier {
+	return &IDTokenVerifier{
+		issuer:   issuer,
+		clientID: clientID,
+		keySet:   keySet,
+		now:      time.Now,
+	}
+}
+
+// Verify checks the signature, iss, aud, azp, exp, iat and nbf of the ID
+// token
+func (v *IDTokenVerifier) Verify(idToken string) error {
+	return v.verify(idToken, 0)
+}
+
+// verify works like Verify. The time based claims are checked against the
+// issuer's clock, which was <issuerClockSkew> seconds ahead of the local
+// clock, when clock skew detection is turned on.
+func (v *IDTokenVerifier) verify(idToken string, issuerClockSkew int64) error {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	claims := jwt.MapClaims{}
+
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unsupported signing algorithm %s", token.Method.Alg())
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return v.keySet.GetKey(kid)
+	})
+	if err != nil {
+		// jwt-go hides the error of the key func, such as the jwks being
+		// unavailable, in a ValidationError that cannot be unwrapped
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Inner != nil {
+			err = validationErr.Inner
+		}
+		return errors.Wrap(err, "could not verify id token signature")
+	}
+
+	return v.verifyClaims(claims, issuerClockSkew)
+}
+
+// verifyClaims checks the registered claims of the ID token
+func (v *IDTokenVerifier) verifyClaims(claims jwt.MapClaims, issuerClockSkew int64) error {
+	if iss, _ := claims["iss"].(string); iss != v.issuer {
+		return fmt.Errorf("id token issuer %q does not match %q", iss, v.issuer)
+	}
+
+	audiences := claimStrings(claims["aud"])
+	if !containsString(audiences, v.clientID) {
+		return fmt.Errorf("id token audience %v does not include the client id", audiences)
+	}
+
+	azp, hasAZP := claims["azp"].(string)
+	if (len(audiences) > 1 || hasAZP) && azp != v.clientID {
+		return fmt.Errorf("id token authorized party %q does not match the client id", azp)
+	}
+
+	now := v.now()
+	if v.detectClockSkew {
+		now = now.Add(time.Duration(issuerClockSkew) * time.Second)
+	}
+
+	skew := idTokenClockSkew
+	if v.clockSkew > skew {
+		skew = v.clockSkew
+	}
+
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), true) {
+		return errors.New("id token has expired")
+	}
+
+	if !claims.VerifyIssuedAt(now.Add(skew).Unix(), true) {
+		return errors.New("id token is missing iat or was issued in the future")
+	}
+
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		return errors.New("id token is not valid yet")
+	}
+
+	return nil
+}
+
+// claimStrings converts a claim that can be a string or an array of strings
+// to a slice
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		var values []string
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// containsString checks if <values> contains <value>
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockKeySet struct {
+	CalledWithKid string
+	ReturnsKey    interface{}
+	ReturnsError  error
+}
+
+func (ks *mockKeySet) GetKey(kid string) (interface{}, error) {
+	ks.CalledWithKid = kid
+	return ks.ReturnsKey, ks.ReturnsError
+}
+
+var _ = Describe("IDTokenVerifier", func() {
+	now := time.Unix(1600000000, 0)
+
+	var key *rsa.PrivateKey
+	var keySet *mockKeySet
+	var verifier *IDTokenVerifier
+	var claims jwt.MapClaims
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+
+		keySet = &mockKeySet{ReturnsKey: &key.PublicKey}
+		verifier = NewIDTokenVerifier("https://issuer/", "clientID", keySet)
+		verifier.now = func() time.Time { return now }
+
+		claims = jwt.MapClaims{
+			"iss": "https://issuer/",
+			"aud": "clientID",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	})
+
+	sign := func() string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid"
+		signed, err := token.SignedString(key)
+		Expect(err).NotTo(HaveOccurred())
+		return signed
+	}
+
+	It("accepts a valid token", func() {
+		Expect(verifier.Verify(sign())).To(Succeed())
+		Expect(keySet.CalledWithKid).To(Equal("kid"))
+	})
+
+	It("rejects a token signed by another key", func() {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		keySet.ReturnsKey = &otherKey.PublicKey
+
+		err = verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("could not verify id token signature: crypto/rsa: verification error"))
+	})
+
+	It("rejects tokens that are not signed with a public key", func() {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		Expect(err).NotTo(HaveOccurred())
+
+		err = verifier.Verify(token)
+
+		Expect(err.Error()).To(Equal("could not verify id token signature: unsupported signing algorithm HS256"))
+	})
+
+	It("errors when the key cannot be found", func() {
+		keySet.ReturnsError = errors.New("uh oh")
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("could not verify id token signature: uh oh"))
+	})
+
+	It("rejects a foreign issuer", func() {
+		claims["iss"] = "https://other/"
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal(`id token issuer "https://other/" does not match "https://issuer/"`))
+	})
+
+	It("rejects a token for another client", func() {
+		claims["aud"] = []string{"otherClientID"}
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("id token audience [otherClientID] does not include the client id"))
+	})
+
+	It("requires azp to match when there are multiple audiences", func() {
+		claims["aud"] = []string{"clientID", "api"}
+
+		Expect(verifier.Verify(sign())).NotTo(Succeed())
+
+		claims["azp"] = "clientID"
+		Expect(verifier.Verify(sign())).To(Succeed())
+	})
+
+	It("rejects an expired token", func() {
+		claims["exp"] = now.Add(-2 * time.Minute).Unix()
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("id token has expired"))
+	})
+
+	It("rejects a token issued in the future", func() {
+		claims["iat"] = now.Add(2 * time.Minute).Unix()
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("id token is missing iat or was issued in the future"))
+	})
+
+	It("rejects a token that is not valid yet", func() {
+		claims["nbf"] = now.Add(2 * time.Minute).Unix()
+
+		err := verifier.Verify(sign())
+
+		Expect(err.Error()).To(Equal("id token is not valid yet"))
+	})
+
+	It("allows for clock skew", func() {
+		claims["iat"] = now.Add(30 * time.Second).Unix()
+		claims["nbf"] = now.Add(30 * time.Second).Unix()
+
+		Expect(verifier.Verify(sign())).To(Succeed())
+	})
+
+	It("allows for the configured clock skew when it is larger", func() {
+		claims["iat"] = now.Add(3 * time.Minute).Unix()
+		token := sign()
+
+		Expect(verifier.Verify(token)).NotTo(Succeed())
+
+		verifier.clockSkew = 5 * time.Minute
+		Expect(verifier.Verify(token)).To(Succeed())
+	})
+
+	It("checks the claims against the issuer's clock when detecting the clock skew", func() {
+		claims["iat"] = now.Add(10 * time.Minute).Unix()
+		claims["exp"] = now.Add(15 * time.Minute).Unix()
+		token := sign()
+
+		Expect(verifier.verify(token, 600)).NotTo(Succeed())
+
+		verifier.detectClockSkew = true
+		Expect(verifier.verify(token, 600)).To(Succeed())
+		Expect(verifier.verify(token, 0)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// jsonWebKey is a single key of a JSON Web Key Set as defined by RFC 7517.
+// Only the members needed for RSA and EC signature keys are decoded.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jsonWebKeySet is the document served at the issuer's jwks_uri
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// RemoteKeySet fetches the signing keys of the issuer from its jwks_uri and
+// caches them by kid. The keys are fetched again when a token is signed with
+// a kid that is not cached so that key rotation is picked up. Keys served from
+// a cache by the transport are revalidated with the issuer in that case.
+type RemoteKeySet struct {
+	jwksURI   string
+	transport HTTPAuthTransport
+
+	mu   sync.Mutex
+	keys map[string]interface{}
+}
+
+// NewRemoteKeySet builds a RemoteKeySet that fetches keys from <jwksURI>
+// using <transport>
+func NewRemoteKeySet(jwksURI string, transport HTTPAuthTransport) *RemoteKeySet {
+	return &RemoteKeySet{
+		jwksURI:   jwksURI,
+		transport: transport,
+	}
+}
+
+// GetKey returns the public key with the passed in kid. When <kid> is empty
+// the only key in the set is returned.
+func (ks *RemoteKeySet) GetKey(kid string) (interface{}, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	keys, fromCache, err := ks.fetch(ks.keys != nil)
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	if fromCache {
+		keys, _, err = ks.fetch(true)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys = keys
+
+		if key, ok := ks.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no signing key with kid %q found at %s", kid, ks.jwksURI)
+}
+
+// lookup finds the key with kid in the cached keys
+func (ks *RemoteKeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// fetch gets the key set from the jwks_uri and parses the signature keys in
+// it. <revalidate> asks a caching transport to check with the issuer instead
+// of serving the keys from its cache. fromCache reports if it did so anyway.
+func (ks *RemoteKeySet) fetch(revalidate bool) (keys map[string]interface{}, fromCache bool, err error) {
+	request, err := http.NewRequest("GET", ks.jwksURI, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not build jwks request")
+	}
+	if revalidate {
+		request.Header.Set("Cache-Control", "no-cache")
+	}
+
+	response, err := ks.transport.Do(request)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not get jwks from url %s", ks.jwksURI)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, false, &metadataStatusError{document: "jwks", url: ks.jwksURI, err: newOAuthError(response)}
+	}
+	defer response.Body.Close()
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(response.Body).Decode(&set); err != nil {
+		return nil, false, errors.Wrap(err, "could not decode json body when getting jwks")
+	}
+
+	keys = map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			// keys of unsupported types are skipped so that one bad key does
+			// not stop the others from being used
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	return keys, response.Header.Get(fromCacheHeader) != "", nil
+}
+
+// publicKey converts the JWK to an *rsa.PublicKey or *ecdsa.PublicKey
+func (jwk jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+// decodeBigInt decodes a base64url encoded big endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode jwk value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+func rsaJWK(kid string, key *rsa.PublicKey) jsonWebKey {
+	return jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+var _ = Describe("RemoteKeySet", func() {
+	var rsaKey *rsa.PrivateKey
+	var ecKey *ecdsa.PrivateKey
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("fetches and parses the RSA and EC keys", func() {
+		ecJWK := publicJWK(&ecKey.PublicKey)
+		transport := &mockTransport{Response: buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{
+			rsaJWK("rsa", &rsaKey.PublicKey),
+			{Kty: "EC", Kid: "ec", Crv: ecJWK["crv"], X: ecJWK["x"], Y: ecJWK["y"]},
+		}})}
+		ks := NewRemoteKeySet("https://issuer/jwks", transport)
+
+		key, err := ks.GetKey("rsa")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(&rsaKey.PublicKey))
+
+		key, err = ks.GetKey("ec")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key.(*ecdsa.PublicKey).X).To(Equal(ecKey.PublicKey.X))
+
+		Expect(transport.Requests).To(HaveLen(1))
+		Expect(transport.Requests[0].URL.String()).To(Equal("https://issuer/jwks"))
+	})
+
+	It("fetches the keys again when the kid is unknown", func() {
+		rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		transport := &mockTransport{
+			Responses: []*http.Response{
+				buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{rsaJWK("old", &rsaKey.PublicKey)}}),
+			},
+			Response: buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{rsaJWK("new", &rotatedKey.PublicKey)}}),
+		}
+		ks := NewRemoteKeySet("https://issuer/jwks", transport)
+
+		_, err = ks.GetKey("old")
+		Expect(err).NotTo(HaveOccurred())
+
+		key, err := ks.GetKey("new")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(&rotatedKey.PublicKey))
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("revalidates cached keys when the kid is unknown", func() {
+		rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		cached := buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{rsaJWK("old", &rsaKey.PublicKey)}})
+		cached.Header = http.Header{fromCacheHeader: []string{"1"}}
+		transport := &mockTransport{
+			Responses: []*http.Response{cached},
+			Response:  buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{rsaJWK("new", &rotatedKey.PublicKey)}}),
+		}
+		ks := NewRemoteKeySet("https://issuer/jwks", transport)
+
+		key, err := ks.GetKey("new")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(&rotatedKey.PublicKey))
+		Expect(transport.Requests).To(HaveLen(2))
+		Expect(transport.Requests[0].Header.Get("Cache-Control")).To(BeEmpty())
+		Expect(transport.Requests[1].Header.Get("Cache-Control")).To(Equal("no-cache"))
+	})
+
+	It("skips keys that are not for signatures", func() {
+		jwk := rsaJWK("enc", &rsaKey.PublicKey)
+		jwk.Use = "enc"
+		ks := NewRemoteKeySet("https://issuer/jwks", &mockTransport{
+			Response: buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{jwk}}),
+		})
+
+		_, err := ks.GetKey("enc")
+
+		Expect(err.Error()).To(Equal(`no signing key with kid "enc" found at https://issuer/jwks`))
+	})
+
+	It("uses the only key when the token has no kid", func() {
+		ks := NewRemoteKeySet("https://issuer/jwks", &mockTransport{
+			Response: buildResponse(200, jsonWebKeySet{Keys: []jsonWebKey{rsaJWK("rsa", &rsaKey.PublicKey)}}),
+		})
+
+		key, err := ks.GetKey("")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(&rsaKey.PublicKey))
+	})
+
+	It("errors when the jwks cannot be fetched", func() {
+		ks := NewRemoteKeySet("https://issuer/jwks", &mockTransport{Response: buildResponse(500, nil)})
+
+		_, err := ks.GetKey("rsa")
+
+		Expect(err.Error()).To(Equal("could not get jwks from url https://issuer/jwks: unexpected status code 500"))
+		var oauthErr *OAuthError
+		Expect(errors.As(err, &oauthErr)).To(BeTrue())
+		Expect(oauthErr.StatusCode).To(Equal(500))
+	})
+})
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JWTBearerExchanger abstracts exchanging a JWT assertion for tokens
+type JWTBearerExchanger interface {
+	ExchangeJWTBearer(req JWTBearerExchangeRequest) (*TokenResult, error)
+}
+
+// assertionFile reads a JWT assertion from a file and only re-reads it when
+// the file has been rotated
+type assertionFile struct {
+	path      string
+	modTime   time.Time
+	size      int64
+	assertion string
+}
+
+// read returns the assertion in the file, re-reading it if the file has
+// changed since it was last read
+func (f *assertionFile) read() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not stat assertion file %s", f.path)
+	}
+
+	if f.assertion != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.assertion, nil
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read assertion file %s", f.path)
+	}
+
+	assertion := strings.TrimSpace(string(b))
+	if assertion == "" {
+		return "", errors.Errorf("assertion file %s is empty", f.path)
+	}
+
+	f.assertion = assertion
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return f.assertion, nil
+}
+
+// JWTBearerTokenProvider satisfies the IssuerTokenProvider interface and
+// gets tokens by presenting an externally issued JWT, such as a projected
+// Kubernetes service account token, as an authorization grant
+type JWTBearerTokenProvider struct {
+	issuerData Issuer
+	assertion  *assertionFile
+	exchanger  JWTBearerExchanger
+}
+
+// NewJWTBearerTokenProvider allows for the easy setup of
+// JWTBearerTokenProvider. The assertion is read from <assertionPath>.
+func NewJWTBearerTokenProvider(
+	issuerData Issuer,
+	assertionPath string,
+	exchanger JWTBearerExchanger) *JWTBearerTokenProvider {
+	return &JWTBearerTokenProvider{
+		issuerData: issuerData,
+		assertion:  &assertionFile{path: assertionPath},
+		exchanger:  exchanger,
+	}
+}
+
+// NewDefaultJWTBearerTokenProvider provides an easy way to build up a default
+// JWT bearer token provider with all the correct configuration
+func NewDefaultJWTBearerTokenProvider(issuerData Issuer, assertionPath string, options ClientOptions) (*JWTBearerTokenProvider, error) {
+	tokenRetriever, _, err := newDefaultTokenRetriever(issuerData, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJWTBearerTokenProvider(
+		issuerData,
+		assertionPath,
+		tokenRetriever), nil
+}
+
+// Authenticate is used to retrieve a TokenResult using the assertion
+func (p *JWTBearerTokenProvider) Authenticate() (*TokenResult, error) {
+	assertion, err := p.assertion.read()
+	if err != nil {
+		return nil, err
+	}
+
+	if exp, ok := jwtExpiry(assertion); !ok || !exp.After(time.Now()) {
+		return nil, errors.Errorf("the assertion in %s is not a valid unexpired JWT", p.assertion.path)
+	}
+
+	tokenResult, err := p.exchanger.ExchangeJWTBearer(JWTBearerExchangeRequest{
+		ClientID:  p.issuerData.ClientID,
+		Assertion: assertion,
+		Audience:  p.issuerData.Audience,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not exchange assertion")
+	}
+
+	return tokenResult, nil
+}
+
+// FromRefreshToken always returns an error as a new assertion should be
+// presented instead of using a refresh token
+func (p *JWTBearerTokenProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
+	return nil, refreshNotSupportedError("refresh tokens are not supported with the jwt bearer grant")
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockJWTBearerExchanger struct {
+	CalledWithRequests []JWTBearerExchangeRequest
+	ReturnsTokens      *TokenResult
+	ReturnsError       error
+}
+
+func (m *mockJWTBearerExchanger) ExchangeJWTBearer(req JWTBearerExchangeRequest) (*TokenResult, error) {
+	m.CalledWithRequests = append(m.CalledWithRequests, req)
+	return m.ReturnsTokens, m.ReturnsError
+}
+
+var _ = Describe("JWTBearerTokenProvider", func() {
+	issuer := Issuer{
+		IssuerEndpoint: "http://issuer",
+		ClientID:       "test_clientID",
+		Audience:       "test_audience",
+	}
+
+	var dir, path string
+	var exchanger *mockJWTBearerExchanger
+	var provider *JWTBearerTokenProvider
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pixy-assertion")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "token")
+
+		exchanger = &mockJWTBearerExchanger{
+			ReturnsTokens: &TokenResult{AccessToken: "accessToken"},
+		}
+		provider = NewJWTBearerTokenProvider(issuer, path, exchanger)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("exchanges the assertion from the file for tokens", func() {
+		assertion := genValidTokenWithExp(time.Now().Add(time.Minute))
+		Expect(ioutil.WriteFile(path, []byte(assertion+"\n"), 0600)).To(Succeed())
+
+		tokens, err := provider.Authenticate()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens).To(Equal(exchanger.ReturnsTokens))
+		Expect(exchanger.CalledWithRequests).To(Equal([]JWTBearerExchangeRequest{{
+			ClientID:  "test_clientID",
+			Assertion: assertion,
+			Audience:  "test_audience",
+		}}))
+	})
+
+	It("re-reads the assertion when the file rotates", func() {
+		first := genValidTokenWithExp(time.Now().Add(time.Minute))
+		Expect(ioutil.WriteFile(path, []byte(first), 0600)).To(Succeed())
+		provider.Authenticate()
+
+		second := genValidTokenWithExp(time.Now().Add(time.Hour))
+		Expect(ioutil.WriteFile(path, []byte(second), 0600)).To(Succeed())
+		Expect(os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))).To(Succeed())
+		provider.Authenticate()
+
+		Expect(exchanger.CalledWithRequests).To(HaveLen(2))
+		Expect(exchanger.CalledWithRequests[1].Assertion).To(Equal(second))
+	})
+
+	It("returns an error when the assertion has expired", func() {
+		Expect(ioutil.WriteFile(path, []byte(genValidTokenWithExp(time.Now().Add(-time.Minute))), 0600)).To(Succeed())
+
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("the assertion in " + path + " is not a valid unexpired JWT"))
+		Expect(exchanger.CalledWithRequests).To(BeEmpty())
+	})
+
+	It("returns an error when the file does not exist", func() {
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when the exchange fails", func() {
+		Expect(ioutil.WriteFile(path, []byte(genValidTokenWithExp(time.Now().Add(time.Minute))), 0600)).To(Succeed())
+		exchanger.ReturnsError = errors.New("someerror")
+
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not exchange assertion: someerror"))
+	})
+
+	It("does not support refresh tokens", func() {
+		tokens, err := provider.FromRefreshToken("refresh")
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("refresh tokens are not supported with the jwt bearer grant"))
+	})
+})
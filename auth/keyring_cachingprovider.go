@@ -13,6 +13,7 @@ import (
 type KeyringProvider interface {
 	Get(key string) (keyring.Item, error)
 	Set(item keyring.Item) error
+	Remove(key string) error
 }
 
 // KeyringCachingProvider satisfies the cachingProvider interface and caches
@@ -81,3 +82,13 @@ func (kcp *KeyringCachingProvider) CacheTokens(tr *TokenResult) error {
 
 	return nil
 }
+
+// ClearTokens removes the TokenResult from keyring
+func (kcp *KeyringCachingProvider) ClearTokens() error {
+	err := kcp.keyring.Remove(kcp.identifier)
+	if err != nil && err != keyring.ErrKeyNotFound {
+		return errors.Wrap(err, "error removing token information from keyring")
+	}
+
+	return nil
+}
@@ -14,6 +14,9 @@ type mockKeyringProvider struct {
 
 	SetCalledWith   keyring.Item
 	SetReturnsError error
+
+	RemoveCalledWith   string
+	RemoveReturnsError error
 }
 
 func (mkp *mockKeyringProvider) Get(key string) (keyring.Item, error) {
@@ -26,6 +29,11 @@ func (mkp *mockKeyringProvider) Set(item keyring.Item) error {
 	return mkp.SetReturnsError
 }
 
+func (mkp *mockKeyringProvider) Remove(key string) error {
+	mkp.RemoveCalledWith = key
+	return mkp.RemoveReturnsError
+}
+
 type mockMarshalToJSON struct {
 	CalledWith   interface{}
 	ReturnsError error
@@ -171,4 +179,32 @@ var _ = Describe("keyringCachingProvider", func() {
 			Data: []byte(`{"access_token":"asdf","id_token":"mnbv","refresh_token":"lkjh","expires_in":0}`),
 		}))
 	})
+
+	Describe("ClearTokens", func() {
+		It("removes the tokens from the keyring", func() {
+			mockKeyring := &mockKeyringProvider{}
+			provider := NewKeyringCachingProvider("clientID", "audience", mockKeyring)
+
+			Expect(provider.ClearTokens()).To(Succeed())
+			Expect(mockKeyring.RemoveCalledWith).To(Equal("clientID-audience"))
+		})
+
+		It("does not error when there are no tokens", func() {
+			provider := NewKeyringCachingProvider("clientID", "audience", &mockKeyringProvider{
+				RemoveReturnsError: keyring.ErrKeyNotFound,
+			})
+
+			Expect(provider.ClearTokens()).To(Succeed())
+		})
+
+		It("errors when removing errors", func() {
+			provider := NewKeyringCachingProvider("clientID", "audience", &mockKeyringProvider{
+				RemoveReturnsError: errors.New("uh oh"),
+			})
+
+			err := provider.ClearTokens()
+
+			Expect(err.Error()).To(Equal("error removing token information from keyring: uh oh"))
+		})
+	})
 })
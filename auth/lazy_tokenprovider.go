@@ -0,0 +1,52 @@
+package auth
+
+import "sync"
+
+// LazyTokenProvider satisfies the IssuerTokenProvider interface and defers
+// building the wrapped IssuerTokenProvider until tokens are actually needed
+// from the issuer. This keeps discovery and any other requests to the issuer
+// off the path where a valid token is already cached.
+type LazyTokenProvider struct {
+	build func() (IssuerTokenProvider, error)
+
+	once     sync.Once
+	provider IssuerTokenProvider
+	err      error
+}
+
+// NewLazyTokenProvider builds a new LazyTokenProvider that calls <build> the
+// first time tokens are needed from the issuer
+func NewLazyTokenProvider(build func() (IssuerTokenProvider, error)) *LazyTokenProvider {
+	return &LazyTokenProvider{build: build}
+}
+
+// getProvider builds the wrapped IssuerTokenProvider once and returns it
+func (l *LazyTokenProvider) getProvider() (IssuerTokenProvider, error) {
+	l.once.Do(func() {
+		l.provider, l.err = l.build()
+	})
+
+	return l.provider, l.err
+}
+
+// Authenticate builds the wrapped IssuerTokenProvider and authenticates with
+// it
+func (l *LazyTokenProvider) Authenticate() (*TokenResult, error) {
+	provider, err := l.getProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.Authenticate()
+}
+
+// FromRefreshToken builds the wrapped IssuerTokenProvider and refreshes the
+// tokens with it
+func (l *LazyTokenProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
+	provider, err := l.getProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.FromRefreshToken(refreshToken)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LazyTokenProvider", func() {
+	var buildCalls int
+	var mockProvider *mockTokenProvider
+
+	BeforeEach(func() {
+		buildCalls = 0
+		mockProvider = &mockTokenProvider{
+			ReturnAuthenticateToken: &TokenResult{AccessToken: "authenticated"},
+			ReturnRefreshToken:      &TokenResult{AccessToken: "refreshed"},
+		}
+	})
+
+	build := func() (IssuerTokenProvider, error) {
+		buildCalls++
+		return mockProvider, nil
+	}
+
+	It("does not build the provider until it is needed", func() {
+		NewLazyTokenProvider(build)
+
+		Expect(buildCalls).To(Equal(0))
+	})
+
+	It("builds the provider once and delegates to it", func() {
+		p := NewLazyTokenProvider(build)
+
+		tokens, err := p.Authenticate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens.AccessToken).To(Equal("authenticated"))
+
+		tokens, err = p.FromRefreshToken("refreshToken")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens.AccessToken).To(Equal("refreshed"))
+		Expect(mockProvider.CalledWithRefreshToken).To(Equal("refreshToken"))
+
+		Expect(buildCalls).To(Equal(1))
+	})
+
+	It("returns the build error", func() {
+		p := NewLazyTokenProvider(func() (IssuerTokenProvider, error) {
+			return nil, errors.New("discovery failed")
+		})
+
+		_, err := p.Authenticate()
+		Expect(err.Error()).To(Equal("discovery failed"))
+
+		_, err = p.FromRefreshToken("refreshToken")
+		Expect(err.Error()).To(Equal("discovery failed"))
+	})
+
+	It("does not touch the issuer when the cache has a valid token", func() {
+		cache := &mockCachingProvider{ReturnToken: &TokenResult{AccessToken: genValidTokenWithExp(time.Now().Add(time.Hour))}}
+		provider := NewCachingTokenProvider(cache, NewLazyTokenProvider(build))
+
+		_, err := provider.GetAccessToken()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buildCalls).To(Equal(0))
+	})
+})
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	goos "os"
+
+	"github.com/auth0/k8s-pixy-auth/os"
+	"github.com/pkg/errors"
+)
+
+// TokenRevoker abstracts revoking tokens at the issuer
+type TokenRevoker interface {
+	RevokeToken(req TokenRevocationRequest) error
+}
+
+// LogoutProvider takes care of signing the user out by revoking the cached
+// refresh token, removing the cached tokens and optionally ending the session
+// at the issuer
+type LogoutProvider struct {
+	issuerData         Issuer
+	cache              cachingProvider
+	revoker            TokenRevoker
+	endSessionEndpoint string
+	osInteractor       OSInteractor
+	output             io.Writer
+	// discoveryErr is why the issuer's endpoints could not be discovered
+	discoveryErr error
+}
+
+// NewLogoutProvider allows for the easy setup of LogoutProvider. <revoker>
+// can be nil when the issuer does not support token revocation and
+// <endSessionEndpoint> can be empty when it does not support ending the
+// session. Notices are written to <output>.
+func NewLogoutProvider(
+	issuerData Issuer,
+	cache cachingProvider,
+	revoker TokenRevoker,
+	endSessionEndpoint string,
+	osInteractor OSInteractor,
+	output io.Writer) *LogoutProvider {
+	return &LogoutProvider{
+		issuerData:         issuerData,
+		cache:              cache,
+		revoker:            revoker,
+		endSessionEndpoint: endSessionEndpoint,
+		osInteractor:       osInteractor,
+		output:             output,
+	}
+}
+
+// NewDefaultLogoutProvider provides an easy way to build up a default
+// LogoutProvider that uses the revocation and end session endpoints the
+// issuer advertises. When the endpoints cannot be discovered the
+// LogoutProvider still removes the cached tokens.
+func NewDefaultLogoutProvider(issuerData Issuer, cache cachingProvider, options ClientOptions) (*LogoutProvider, error) {
+	tokenRetriever, wellKnownEndpoints, err := newDefaultTokenRetriever(issuerData, options)
+	if err != nil {
+		logoutProvider := NewLogoutProvider(issuerData, cache, nil, "", &os.DefaultInteractor{}, goos.Stderr)
+		logoutProvider.discoveryErr = err
+		return logoutProvider, nil
+	}
+
+	var revoker TokenRevoker
+	if wellKnownEndpoints.RevocationEndpoint != "" {
+		revoker = tokenRetriever
+	}
+
+	return NewLogoutProvider(
+		issuerData,
+		cache,
+		revoker,
+		wellKnownEndpoints.EndSessionEndpoint,
+		&os.DefaultInteractor{},
+		goos.Stderr), nil
+}
+
+// Logout removes the cached tokens and then revokes the refresh token. The
+// cached tokens are removed whatever happens at the issuer, and a refresh
+// token that could not be revoked is reported on the output. When
+// <endSession> is true the end session endpoint is opened so that the session
+// at the issuer ends too.
+func (l *LogoutProvider) Logout(endSession bool) error {
+	tokenResult, getErr := l.cache.GetTokens()
+
+	if err := l.cache.ClearTokens(); err != nil {
+		return errors.Wrap(err, "could not remove the cached tokens")
+	}
+
+	if getErr != nil {
+		fmt.Fprintf(l.output, "Could not read the cached tokens for %s so the refresh token was not revoked: %s\n", l.issuerData.Audience, getErr)
+	} else if tokenResult != nil && tokenResult.RefreshToken != "" {
+		l.revoke(tokenResult.RefreshToken)
+	}
+
+	if !endSession {
+		return nil
+	}
+
+	if l.discoveryErr != nil {
+		return errors.Wrap(l.discoveryErr, "could not end the session at the issuer")
+	}
+
+	if l.endSessionEndpoint == "" {
+		return errors.New("the issuer does not advertise an end_session_endpoint")
+	}
+
+	params := url.Values{"client_id": []string{l.issuerData.ClientID}}
+	if tokenResult != nil && tokenResult.IDToken != "" {
+		params.Set("id_token_hint", tokenResult.IDToken)
+	}
+
+	return l.osInteractor.OpenURL(fmt.Sprintf("%s?%s", l.endSessionEndpoint, params.Encode()))
+}
+
+// revoke revokes the refresh token and reports on the output when that is
+// not possible
+func (l *LogoutProvider) revoke(refreshToken string) {
+	switch {
+	case l.discoveryErr != nil:
+		fmt.Fprintf(l.output, "Could not get the issuer's endpoints so the refresh token for %s could not be revoked: %s\n", l.issuerData.Audience, l.discoveryErr)
+	case l.revoker == nil:
+		fmt.Fprintf(l.output, "The issuer does not advertise a revocation_endpoint so the refresh token for %s could not be revoked\n", l.issuerData.Audience)
+	default:
+		err := l.revoker.RevokeToken(TokenRevocationRequest{
+			ClientID:      l.issuerData.ClientID,
+			Token:         refreshToken,
+			TokenTypeHint: "refresh_token",
+		})
+		if err != nil {
+			fmt.Fprintf(l.output, "Could not revoke the refresh token for %s: %s\n", l.issuerData.Audience, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockTokenRevoker struct {
+	CalledWith   *TokenRevocationRequest
+	ReturnsError error
+}
+
+func (r *mockTokenRevoker) RevokeToken(req TokenRevocationRequest) error {
+	r.CalledWith = &req
+	return r.ReturnsError
+}
+
+var _ = Describe("LogoutProvider", func() {
+	issuerData := Issuer{
+		IssuerEndpoint: "https://issuer",
+		ClientID:       "clientID",
+		Audience:       "audience",
+	}
+
+	var cache *mockCachingProvider
+	var revoker *mockTokenRevoker
+	var interactor *mockInteractor
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		cache = &mockCachingProvider{
+			ReturnToken: &TokenResult{IDToken: "idToken", RefreshToken: "refreshToken"},
+		}
+		revoker = &mockTokenRevoker{}
+		interactor = &mockInteractor{}
+		output = &bytes.Buffer{}
+	})
+
+	It("revokes the refresh token and clears the cache", func() {
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(revoker.CalledWith).To(Equal(&TokenRevocationRequest{
+			ClientID:      "clientID",
+			Token:         "refreshToken",
+			TokenTypeHint: "refresh_token",
+		}))
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(interactor.URL).To(BeEmpty())
+	})
+
+	It("clears the cache when there is no refresh token", func() {
+		cache.ReturnToken = &TokenResult{AccessToken: "accessToken"}
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(revoker.CalledWith).To(BeNil())
+		Expect(cache.ClearCalled).To(BeTrue())
+	})
+
+	It("clears the cache and tells the user when revocation is not supported", func() {
+		p := NewLogoutProvider(issuerData, cache, nil, "", interactor, output)
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(output.String()).To(ContainSubstring("could not be revoked"))
+	})
+
+	It("clears the cache even when revocation fails", func() {
+		revoker.ReturnsError = errors.New("uh oh")
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(output.String()).To(Equal("Could not revoke the refresh token for audience: uh oh\n"))
+	})
+
+	It("clears the cache and tells the user when the endpoints could not be discovered", func() {
+		p := NewLogoutProvider(issuerData, cache, nil, "", interactor, output)
+		p.discoveryErr = errors.New("issuer unreachable")
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(output.String()).To(ContainSubstring("issuer unreachable"))
+	})
+
+	It("clears the cache when it cannot be read", func() {
+		cache.GetReturnsError = errors.New("uh oh")
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		Expect(p.Logout(false)).To(Succeed())
+
+		Expect(cache.ClearCalled).To(BeTrue())
+		Expect(revoker.CalledWith).To(BeNil())
+		Expect(output.String()).To(ContainSubstring("uh oh"))
+	})
+
+	It("errors when the cache cannot be cleared", func() {
+		cache.ClearReturnsError = errors.New("uh oh")
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		err := p.Logout(false)
+
+		Expect(err.Error()).To(Equal("could not remove the cached tokens: uh oh"))
+	})
+
+	It("opens the end session endpoint with the id token hint", func() {
+		p := NewLogoutProvider(issuerData, cache, revoker, "https://issuer/logout", interactor, output)
+
+		Expect(p.Logout(true)).To(Succeed())
+
+		parsedURL, err := url.Parse(interactor.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsedURL.Path).To(Equal("/logout"))
+		Expect(parsedURL.Query()).To(Equal(url.Values{
+			"client_id":     []string{"clientID"},
+			"id_token_hint": []string{"idToken"},
+		}))
+	})
+
+	It("errors when ending the session is not supported", func() {
+		p := NewLogoutProvider(issuerData, cache, revoker, "", interactor, output)
+
+		err := p.Logout(true)
+
+		Expect(err.Error()).To(Equal("the issuer does not advertise an end_session_endpoint"))
+		Expect(cache.ClearCalled).To(BeTrue())
+	})
+})
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ManualCodeEntry satisfies both the OSInteractor and the
+// AuthorizationCallbackListener interfaces for sessions without a browser. The
+// authorization URL is printed instead of being opened and the user pastes
+// either the code or the full URL they were redirected to once they have
+// logged in on another device.
+type ManualCodeEntry struct {
+	callbackURL string
+	input       io.Reader
+	output      io.Writer
+}
+
+// NewManualCodeEntry builds a ManualCodeEntry that expects the redirect to
+// the local callback on <port>, reads the pasted code from <input> and writes
+// its instructions to <output>
+func NewManualCodeEntry(port int, input io.Reader, output io.Writer) *ManualCodeEntry {
+	return &ManualCodeEntry{
+		callbackURL: fmt.Sprintf("http://127.0.0.1:%d/callback", port),
+		input:       input,
+		output:      output,
+	}
+}
+
+// OpenURL prints the authorization URL along with instructions on how to
+// complete the login
+func (m *ManualCodeEntry) OpenURL(url string) error {
+	_, err := fmt.Fprintf(m.output, "Open the following URL in a browser on any device to authenticate:\n\n%s\n\n"+
+		"Once you have logged in, paste the code or the full URL you were redirected to: ", url)
+	return err
+}
+
+// GetCallbackURL returns the callback url that is used to receive the
+// authorization code
+func (m *ManualCodeEntry) GetCallbackURL() string {
+	return m.callbackURL
+}
+
+// AwaitResponse reads the pasted code or redirected URL and sends the
+// resulting CallbackResponse to <response>. The state is validated when a URL
+// is pasted.
+func (m *ManualCodeEntry) AwaitResponse(response chan CallbackResponse, state string) {
+	line, err := bufio.NewReader(m.input).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err == nil || err == io.EOF {
+			err = errors.New("no authorization code was entered")
+		}
+		response <- CallbackResponse{Error: err}
+		return
+	}
+
+	response <- parseManualCodeEntry(line, state)
+}
+
+// Close does nothing as there is nothing to shut down
+func (m *ManualCodeEntry) Close() {}
+
+// parseManualCodeEntry builds the CallbackResponse from a pasted code or
+// redirected URL
+func parseManualCodeEntry(entry, state string) CallbackResponse {
+	if !strings.Contains(entry, "?") {
+		return CallbackResponse{Code: entry}
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil {
+		return CallbackResponse{Error: errors.Wrap(err, "could not parse the redirected url")}
+	}
+
+	return callbackResponseFromQuery(u.Query(), state)
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ManualCodeEntry", func() {
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+	})
+
+	awaitEntry := func(input, state string) CallbackResponse {
+		entry := NewManualCodeEntry(8080, strings.NewReader(input), output)
+
+		resp := make(chan CallbackResponse, 1)
+		entry.AwaitResponse(resp, state)
+
+		return <-resp
+	}
+
+	It("prints the url instead of opening it", func() {
+		entry := NewManualCodeEntry(8080, strings.NewReader(""), output)
+
+		Expect(entry.OpenURL("https://issuer/authorize?a=b")).To(Succeed())
+
+		Expect(output.String()).To(ContainSubstring("\nhttps://issuer/authorize?a=b\n"))
+		Expect(output.String()).To(HaveSuffix("paste the code or the full URL you were redirected to: "))
+	})
+
+	It("uses the local callback url as the redirect uri", func() {
+		entry := NewManualCodeEntry(1234, strings.NewReader(""), output)
+
+		Expect(entry.GetCallbackURL()).To(Equal("http://127.0.0.1:1234/callback"))
+	})
+
+	It("accepts a pasted code", func() {
+		Expect(awaitEntry("  mycode \n", "state")).To(Equal(CallbackResponse{Code: "mycode"}))
+	})
+
+	It("accepts the redirected url", func() {
+		Expect(awaitEntry("http://127.0.0.1:8080/callback?code=mycode&state=state\n", "state")).To(Equal(CallbackResponse{Code: "mycode"}))
+	})
+
+	It("errors when the redirected url has the wrong state", func() {
+		resp := awaitEntry("http://127.0.0.1:8080/callback?code=mycode&state=other\n", "state")
+
+		Expect(resp.Error.Error()).To(Equal("callback completed with incorrect state"))
+	})
+
+	It("returns the error from the redirected url", func() {
+		resp := awaitEntry("http://127.0.0.1:8080/callback?error=access_denied&error_description=no&state=state\n", "state")
+
+		Expect(resp.Error.Error()).To(Equal("access_denied: no"))
+	})
+
+	It("errors when nothing is entered", func() {
+		resp := awaitEntry("", "state")
+
+		Expect(resp.Error.Error()).To(Equal("no authorization code was entered"))
+	})
+})
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fromCacheHeader is set on responses that were served from the cache
+// without contacting the issuer
+const fromCacheHeader = "X-From-Cache"
+
+// notFoundCacheDuration is how long a document that does not exist is
+// remembered so that discovery does not ask for it on every run while an
+// issuer that starts publishing it is noticed soon
+const notFoundCacheDuration = 5 * time.Minute
+
+// metadataCacheEntry is a cached response body with the validators needed
+// to revalidate it. StatusCode is only set for documents that do not exist.
+type metadataCacheEntry struct {
+	StatusCode   int       `json:"status_code,omitempty"`
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+// MetadataCacheTransport satisfies the HTTPAuthTransport interface and keeps
+// the issuer's discovery and JWKS documents on disk. A cached document is used
+// as is while it is fresh according to Cache-Control or Expires and is
+// revalidated with If-None-Match and If-Modified-Since once it is stale.
+// Documents that do not exist are remembered for notFoundCacheDuration.
+type MetadataCacheTransport struct {
+	file      string
+	transport HTTPAuthTransport
+	refresh   bool
+	now       func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewMetadataCacheTransport builds a MetadataCacheTransport that caches the
+// documents of <issuer> in a file in <dir> and gets them using <transport>.
+// Cached documents are ignored and replaced when <refresh> is set.
+func NewMetadataCacheTransport(dir, issuer string, transport HTTPAuthTransport, refresh bool) *MetadataCacheTransport {
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(issuer, "/")))
+
+	return &MetadataCacheTransport{
+		file:      filepath.Join(dir, hex.EncodeToString(sum[:])+".json"),
+		transport: transport,
+		refresh:   refresh,
+		now:       time.Now,
+	}
+}
+
+// Do sends GET requests through the cache. Other requests are passed to the
+// wrapped transport untouched. A request with Cache-Control: no-cache is
+// always revalidated with the issuer.
+func (t *MetadataCacheTransport) Do(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet {
+		return t.transport.Do(request)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := request.URL.String()
+	entries := t.load()
+	entry, cached := entries[key]
+	cached = cached && !t.refresh
+
+	if cached && !hasCacheDirective(request.Header.Get("Cache-Control"), "no-cache") && t.now().Before(entry.Expires) {
+		return entry.response(request, true), nil
+	}
+
+	request = request.Clone(request.Context())
+	if cached && entry.ETag != "" {
+		request.Header.Set("If-None-Match", entry.ETag)
+	}
+	if cached && entry.LastModified != "" {
+		request.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+
+	response, err := t.transport.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotModified && cached:
+		response.Body.Close()
+
+		entry.Expires = t.expires(response.Header)
+		if etag := response.Header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		if lastModified := response.Header.Get("Last-Modified"); lastModified != "" {
+			entry.LastModified = lastModified
+		}
+		entries[key] = entry
+		t.store(entries)
+
+		return entry.response(request, false), nil
+	case response.StatusCode == http.StatusOK || response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		if hasCacheDirective(response.Header.Get("Cache-Control"), "no-store") {
+			return response, nil
+		}
+
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read response from url %s", key)
+		}
+		response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		entry := metadataCacheEntry{
+			Body:         body,
+			ETag:         response.Header.Get("ETag"),
+			LastModified: response.Header.Get("Last-Modified"),
+			Expires:      t.expires(response.Header),
+		}
+		if response.StatusCode != http.StatusOK {
+			entry = metadataCacheEntry{
+				StatusCode: response.StatusCode,
+				Body:       body,
+				Expires:    t.now().Add(notFoundCacheDuration),
+			}
+		}
+		entries[key] = entry
+		t.store(entries)
+	}
+
+	return response, nil
+}
+
+// expires works out until when a response is fresh. Responses without
+// freshness information are stored but revalidated every time.
+func (t *MetadataCacheTransport) expires(header http.Header) time.Time {
+	now := t.now()
+	cacheControl := header.Get("Cache-Control")
+
+	if hasCacheDirective(cacheControl, "no-cache") {
+		return now
+	}
+
+	if maxAge, ok := cacheDirectiveSeconds(cacheControl, "max-age"); ok {
+		age, _ := strconv.Atoi(header.Get("Age"))
+		return now.Add(time.Duration(maxAge-age) * time.Second)
+	}
+
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return expires
+	}
+
+	return now
+}
+
+// load reads the cached entries. A missing or unreadable cache file is
+// treated as an empty cache.
+func (t *MetadataCacheTransport) load() map[string]metadataCacheEntry {
+	entries := map[string]metadataCacheEntry{}
+
+	b, err := ioutil.ReadFile(t.file)
+	if err != nil {
+		return entries
+	}
+
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return map[string]metadataCacheEntry{}
+	}
+
+	return entries
+}
+
+// store writes the entries to the cache file. The file is replaced atomically
+// so that concurrent processes never read a partial file. Errors are ignored
+// as the cache is only an optimisation.
+func (t *MetadataCacheTransport) store(entries map[string]metadataCacheEntry) {
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.file), 0700); err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(t.file), filepath.Base(t.file)+".tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+
+	os.Rename(tmp.Name(), t.file)
+}
+
+// response builds the response for a cached entry
+func (e metadataCacheEntry) response(request *http.Request, fromCache bool) *http.Response {
+	statusCode := http.StatusOK
+	if e.StatusCode != 0 {
+		statusCode = e.StatusCode
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if fromCache {
+		header.Set(fromCacheHeader, "1")
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       request,
+	}
+}
+
+// hasCacheDirective checks if the Cache-Control header value contains the
+// directive
+func hasCacheDirective(cacheControl, directive string) bool {
+	for _, d := range strings.Split(cacheControl, ",") {
+		name := strings.SplitN(strings.TrimSpace(d), "=", 2)[0]
+		if strings.EqualFold(name, directive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cacheDirectiveSeconds gets the delta-seconds value of a Cache-Control
+// directive
+func cacheDirectiveSeconds(cacheControl, directive string) (int, bool) {
+	for _, d := range strings.Split(cacheControl, ",") {
+		parts := strings.SplitN(strings.TrimSpace(d), "=", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], directive) {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.Trim(parts[1], `"`))
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+
+		return seconds, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func buildResponseWithHeader(statusCode int, body interface{}, header http.Header) *http.Response {
+	response := buildResponse(statusCode, body)
+	response.Header = header
+	return response
+}
+
+func getURL(transport HTTPAuthTransport, url string) (*http.Response, string) {
+	request, err := http.NewRequest("GET", url, nil)
+	Expect(err).NotTo(HaveOccurred())
+
+	response, err := transport.Do(request)
+	Expect(err).NotTo(HaveOccurred())
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	Expect(err).NotTo(HaveOccurred())
+
+	return response, string(body)
+}
+
+var _ = Describe("MetadataCacheTransport", func() {
+	const documentURL = "https://issuer/.well-known/openid-configuration"
+
+	var dir string
+	var now time.Time
+
+	newCacheTransport := func(transport HTTPAuthTransport, refresh bool) *MetadataCacheTransport {
+		cache := NewMetadataCacheTransport(dir, "https://issuer", transport, refresh)
+		cache.now = func() time.Time { return now }
+		return cache
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "metadata-cache")
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("serves fresh documents from the cache", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "document", http.Header{
+			"Cache-Control": []string{"public, max-age=3600"},
+		})}
+
+		_, body := getURL(newCacheTransport(transport, false), documentURL)
+		Expect(body).To(Equal(`"document"`))
+
+		now = now.Add(59 * time.Minute)
+		response, body := getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(body).To(Equal(`"document"`))
+		Expect(response.Header.Get(fromCacheHeader)).To(Equal("1"))
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("takes the age of the response into account", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "document", http.Header{
+			"Cache-Control": []string{"max-age=3600"},
+			"Age":           []string{"3000"},
+		})}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		now = now.Add(11 * time.Minute)
+		transport.Response = buildResponse(200, "document")
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("uses the Expires header when there is no max-age", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "document", http.Header{
+			"Expires": []string{now.Add(time.Hour).Format(http.TimeFormat)},
+		})}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("revalidates stale documents with the validators", func() {
+		transport := &mockTransport{
+			Responses: []*http.Response{
+				buildResponseWithHeader(200, "document", http.Header{
+					"Cache-Control": []string{"max-age=60"},
+					"Etag":          []string{`"v1"`},
+					"Last-Modified": []string{"Wed, 01 Jan 2020 00:00:00 GMT"},
+				}),
+				buildResponseWithHeader(304, nil, http.Header{
+					"Cache-Control": []string{"max-age=60"},
+				}),
+			},
+		}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		now = now.Add(2 * time.Minute)
+		response, body := getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(body).To(Equal(`"document"`))
+		Expect(response.StatusCode).To(Equal(200))
+		Expect(response.Header.Get(fromCacheHeader)).To(BeEmpty())
+		Expect(transport.Requests).To(HaveLen(2))
+		Expect(transport.Requests[1].Header.Get("If-None-Match")).To(Equal(`"v1"`))
+		Expect(transport.Requests[1].Header.Get("If-Modified-Since")).To(Equal("Wed, 01 Jan 2020 00:00:00 GMT"))
+
+		getURL(newCacheTransport(transport, false), documentURL)
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("replaces stale documents that changed", func() {
+		transport := &mockTransport{
+			Responses: []*http.Response{
+				buildResponseWithHeader(200, "old", http.Header{"Etag": []string{`"v1"`}}),
+				buildResponseWithHeader(200, "new", http.Header{"Etag": []string{`"v2"`}}),
+			},
+			Response: buildResponseWithHeader(304, nil, http.Header{}),
+		}
+		getURL(newCacheTransport(transport, false), documentURL)
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		_, body := getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(body).To(Equal(`"new"`))
+		Expect(transport.Requests[2].Header.Get("If-None-Match")).To(Equal(`"v2"`))
+	})
+
+	It("revalidates when the request asks for no-cache", func() {
+		transport := &mockTransport{
+			Responses: []*http.Response{
+				buildResponseWithHeader(200, "document", http.Header{"Cache-Control": []string{"max-age=3600"}}),
+			},
+			Response: buildResponseWithHeader(304, nil, http.Header{}),
+		}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		request, err := http.NewRequest("GET", documentURL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Cache-Control", "no-cache")
+		_, err = newCacheTransport(transport, false).Do(request)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("does not store documents marked no-store", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "document", http.Header{
+			"Cache-Control": []string{"no-store, max-age=3600"},
+		})}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		transport.Response = buildResponse(200, "document")
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(transport.Requests).To(HaveLen(2))
+		Expect(transport.Requests[1].Header.Get("If-None-Match")).To(BeEmpty())
+	})
+
+	It("ignores the cache when refreshing", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "old", http.Header{
+			"Cache-Control": []string{"max-age=3600"},
+			"Etag":          []string{`"v1"`},
+		})}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		transport.Response = buildResponseWithHeader(200, "new", http.Header{"Cache-Control": []string{"max-age=3600"}})
+		_, body := getURL(newCacheTransport(transport, true), documentURL)
+
+		Expect(body).To(Equal(`"new"`))
+		Expect(transport.Requests[1].Header.Get("If-None-Match")).To(BeEmpty())
+
+		_, body = getURL(newCacheTransport(transport, false), documentURL)
+		Expect(body).To(Equal(`"new"`))
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("keeps the documents of each issuer apart", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(200, "document", http.Header{
+			"Cache-Control": []string{"max-age=3600"},
+		})}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		transport.Response = buildResponse(200, "other")
+		other := NewMetadataCacheTransport(dir, "https://other-issuer", transport, false)
+		_, body := getURL(other, documentURL)
+
+		Expect(body).To(Equal(`"other"`))
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("does not cache error responses", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(500, nil, http.Header{
+			"Cache-Control": []string{"max-age=3600"},
+		})}
+		response, _ := getURL(newCacheTransport(transport, false), documentURL)
+		Expect(response.StatusCode).To(Equal(500))
+
+		transport.Response = buildResponse(500, nil)
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("remembers documents that do not exist for a short time", func() {
+		transport := &mockTransport{Responses: []*http.Response{buildResponse(404, nil)}, Response: buildResponse(200, "document")}
+		response, _ := getURL(newCacheTransport(transport, false), documentURL)
+		Expect(response.StatusCode).To(Equal(404))
+
+		now = now.Add(notFoundCacheDuration - time.Second)
+		response, _ = getURL(newCacheTransport(transport, false), documentURL)
+		Expect(response.StatusCode).To(Equal(404))
+		Expect(response.Header.Get(fromCacheHeader)).To(Equal("1"))
+		Expect(transport.Requests).To(HaveLen(1))
+
+		now = now.Add(time.Second)
+		response, body := getURL(newCacheTransport(transport, false), documentURL)
+		Expect(response.StatusCode).To(Equal(200))
+		Expect(body).To(Equal(`"document"`))
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("asks for documents that did not exist again when refreshing", func() {
+		transport := &mockTransport{Responses: []*http.Response{buildResponse(404, nil)}, Response: buildResponse(200, "document")}
+		getURL(newCacheTransport(transport, false), documentURL)
+
+		response, _ := getURL(newCacheTransport(transport, true), documentURL)
+
+		Expect(response.StatusCode).To(Equal(200))
+		Expect(transport.Requests).To(HaveLen(2))
+	})
+
+	It("passes other methods through", func() {
+		transport := &mockTransport{Response: buildResponse(200, "ok")}
+		request, err := http.NewRequest("POST", "https://issuer/token", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		response, err := newCacheTransport(transport, false).Do(request)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response).To(Equal(transport.Response))
+		Expect(transport.Requests[0]).To(Equal(request))
+	})
+})
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// LoadClientCertificate loads the PEM encoded client certificate and private
+// key used for mutual TLS
+func LoadClientCertificate(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load client certificate")
+	}
+
+	return &cert, nil
+}
+
+// CertificateThumbprint returns the base64url encoded SHA-256 thumbprint of
+// the DER encoded leaf certificate. This is the same value RFC 8705 puts in
+// the x5t#S256 confirmation claim of certificate-bound tokens.
+func CertificateThumbprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(cert.Certificate[0])
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// newMTLSHTTPClient builds an http.Client that presents <cert> when the
+// server asks for a client certificate
+func newMTLSHTTPClient(cert tls.Certificate) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func writeSelfSignedCertificate(dir string) (certFile, keyFile string, der []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pixy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err = x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	Expect(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+
+	return certFile, keyFile, der
+}
+
+var _ = Describe("mTLS", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pixy-mtls")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("loads the client certificate and computes its thumbprint", func() {
+		certFile, keyFile, der := writeSelfSignedCertificate(dir)
+
+		cert, err := LoadClientCertificate(certFile, keyFile)
+
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256(der)
+		Expect(CertificateThumbprint(*cert)).To(Equal(base64.RawURLEncoding.EncodeToString(sum[:])))
+	})
+
+	It("errors when the certificate cannot be loaded", func() {
+		cert, err := LoadClientCertificate(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing-key.pem"))
+
+		Expect(cert).To(BeNil())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("presents the certificate from the http client", func() {
+		certFile, keyFile, _ := writeSelfSignedCertificate(dir)
+		cert, err := LoadClientCertificate(certFile, keyFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		client := newMTLSHTTPClient(*cert)
+
+		Expect(client.Transport.(*http.Transport).TLSClientConfig.Certificates).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,31 @@
+package auth
+
+import "errors"
+
+// ErrInteractionRequired is returned when the user has to log in but cannot
+// be asked to
+var ErrInteractionRequired = errors.New("you need to log in but kubectl did not provide an interactive terminal: run a kubectl command in a terminal to log in and try again")
+
+// NonInteractiveTokenProvider satisfies the IssuerTokenProvider interface and
+// wraps an IssuerTokenProvider that needs the user to authenticate. Refreshing
+// tokens is passed through while authenticating fails right away with
+// ErrInteractionRequired.
+type NonInteractiveTokenProvider struct {
+	provider IssuerTokenProvider
+}
+
+// NewNonInteractiveTokenProvider builds a new NonInteractiveTokenProvider
+// that refreshes tokens using <provider>
+func NewNonInteractiveTokenProvider(provider IssuerTokenProvider) *NonInteractiveTokenProvider {
+	return &NonInteractiveTokenProvider{provider: provider}
+}
+
+// Authenticate returns ErrInteractionRequired as the user cannot log in
+func (n *NonInteractiveTokenProvider) Authenticate() (*TokenResult, error) {
+	return nil, ErrInteractionRequired
+}
+
+// FromRefreshToken refreshes the tokens using the wrapped provider
+func (n *NonInteractiveTokenProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
+	return n.provider.FromRefreshToken(refreshToken)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NonInteractiveTokenProvider", func() {
+	var mockProvider *mockTokenProvider
+	var p *NonInteractiveTokenProvider
+
+	BeforeEach(func() {
+		mockProvider = &mockTokenProvider{
+			ReturnAuthenticateToken: &TokenResult{AccessToken: "authenticated"},
+			ReturnRefreshToken:      &TokenResult{AccessToken: "refreshed"},
+		}
+		p = NewNonInteractiveTokenProvider(mockProvider)
+	})
+
+	It("fails to authenticate without asking the user", func() {
+		tokens, err := p.Authenticate()
+
+		Expect(err).To(Equal(ErrInteractionRequired))
+		Expect(tokens).To(BeNil())
+		Expect(mockProvider.CalledAuthenticate).To(BeFalse())
+	})
+
+	It("refreshes tokens using the wrapped provider", func() {
+		tokens, err := p.FromRefreshToken("refreshToken")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens.AccessToken).To(Equal("refreshed"))
+		Expect(mockProvider.CalledWithRefreshToken).To(Equal("refreshToken"))
+	})
+})
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxErrorBodySize limits how much of an error response body is read
+const maxErrorBodySize = 1 << 20
+
+// OAuthError is an error returned by the issuer as defined by RFC 6749
+// section 5.2. The HTTP status code and WWW-Authenticate header are kept when
+// the error came from a response. Errors sent to the redirect URI by the
+// authorization endpoint have no status code. Use errors.As to inspect it.
+type OAuthError struct {
+	// Code is the error code, for example invalid_client or invalid_grant.
+	// It is empty when the response did not have an error body.
+	Code            string
+	Description     string
+	URI             string
+	StatusCode      int
+	WWWAuthenticate string
+}
+
+func (e *OAuthError) Error() string {
+	switch {
+	case e.Code == "":
+		return fmt.Sprintf("A non-success status code was receveived: %d", e.StatusCode)
+	case e.Description == "":
+		return e.Code
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Is matches the sentinel errors that stand for an error code, such as
+// ErrInvalidGrant, so that errors.Is can be used with them
+func (e *OAuthError) Is(target error) bool {
+	switch target {
+	case ErrInvalidGrant, ErrAuthorizationPending, ErrSlowDown:
+		return e.Code == target.Error()
+	}
+
+	return false
+}
+
+// Temporary checks if the issuer could not handle the request right now,
+// because it failed or was rate limiting, in which case trying again later
+// may work
+func (e *OAuthError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests ||
+		e.Code == "server_error" || e.Code == "temporarily_unavailable"
+}
+
+// newOAuthError reads the error from a response that was not successful. The
+// body is only used when it is an RFC 6749 error response, so that HTML error
+// pages end up as the status code alone. The body is closed.
+func newOAuthError(resp *http.Response) *OAuthError {
+	defer resp.Body.Close()
+
+	oauthErr := &OAuthError{
+		StatusCode:      resp.StatusCode,
+		WWWAuthenticate: resp.Header.Get("WWW-Authenticate"),
+	}
+
+	ter := tokenErrorResponse{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBodySize)).Decode(&ter); err == nil {
+		oauthErr.Code = ter.Error
+		oauthErr.Description = ter.ErrorDescription
+		oauthErr.URI = ter.ErrorURI
+	}
+
+	return oauthErr
+}
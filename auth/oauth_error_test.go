@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("OAuthError", func() {
+	It("includes the description in the message when there is one", func() {
+		Expect((&OAuthError{Code: "invalid_client", Description: "unknown client"}).Error()).To(Equal("invalid_client: unknown client"))
+		Expect((&OAuthError{Code: "access_denied"}).Error()).To(Equal("access_denied"))
+	})
+
+	It("reports the status code when there is no error code", func() {
+		Expect((&OAuthError{StatusCode: 502}).Error()).To(Equal("A non-success status code was receveived: 502"))
+	})
+
+	It("matches the sentinel errors for its code", func() {
+		err := errors.Wrap(&OAuthError{Code: "invalid_grant"}, "could not refresh")
+
+		Expect(errors.Is(err, ErrInvalidGrant)).To(BeTrue())
+		Expect(errors.Is(err, ErrSlowDown)).To(BeFalse())
+		Expect(errors.Is(&OAuthError{Code: "slow_down"}, ErrSlowDown)).To(BeTrue())
+	})
+
+	It("is temporary for server errors", func() {
+		Expect((&OAuthError{StatusCode: 503}).Temporary()).To(BeTrue())
+		Expect((&OAuthError{Code: "server_error", StatusCode: 400}).Temporary()).To(BeTrue())
+		Expect((&OAuthError{StatusCode: 429}).Temporary()).To(BeTrue())
+		Expect((&OAuthError{Code: "invalid_request", StatusCode: 400}).Temporary()).To(BeFalse())
+	})
+
+	Describe("newOAuthError", func() {
+		It("reads the error body, status code and WWW-Authenticate header", func() {
+			response := buildResponse(401, tokenErrorResponse{Error: "invalid_token", ErrorDescription: "expired", ErrorURI: "https://issuer/errors"})
+			response.Header = http.Header{"Www-Authenticate": []string{`DPoP error="invalid_token"`}}
+
+			Expect(newOAuthError(response)).To(Equal(&OAuthError{
+				Code:            "invalid_token",
+				Description:     "expired",
+				URI:             "https://issuer/errors",
+				StatusCode:      401,
+				WWWAuthenticate: `DPoP error="invalid_token"`,
+			}))
+		})
+
+		It("ignores bodies that are not OAuth errors", func() {
+			response := &http.Response{
+				StatusCode: 502,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("<html>Bad Gateway</html>")),
+			}
+
+			Expect(newOAuthError(response)).To(Equal(&OAuthError{StatusCode: 502}))
+		})
+	})
+})
@@ -2,37 +2,313 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
-	"path"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
-// OIDCWellKnownEndpoints holds the well known OIDC endpoints
+// OIDCWellKnownEndpoints holds the OpenID Provider metadata as defined by
+// OpenID Connect Discovery 1.0 and the OAuth extensions that add to it
 type OIDCWellKnownEndpoints struct {
-	AuthorizationEndpoint string `json:"authorization_endpoint"`
-	TokenEndpoint         string `json:"token_endpoint"`
+	Issuer                             string `json:"issuer"`
+	AuthorizationEndpoint              string `json:"authorization_endpoint"`
+	TokenEndpoint                      string `json:"token_endpoint"`
+	UserinfoEndpoint                   string `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                            string `json:"jwks_uri,omitempty"`
+	RegistrationEndpoint               string `json:"registration_endpoint,omitempty"`
+	RevocationEndpoint                 string `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint              string `json:"introspection_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint        string `json:"device_authorization_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint,omitempty"`
+	EndSessionEndpoint                 string `json:"end_session_endpoint,omitempty"`
+
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported           []string `json:"response_types_supported,omitempty"`
+	ResponseModesSupported           []string `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported              []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported            []string `json:"subject_types_supported,omitempty"`
+	ClaimsSupported                  []string `json:"claims_supported,omitempty"`
+	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	DPoPSigningAlgValuesSupported    []string `json:"dpop_signing_alg_values_supported,omitempty"`
+
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	RevocationEndpointAuthMethodsSupported     []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported  []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+
+	RequirePushedAuthorizationRequests    bool `json:"require_pushed_authorization_requests,omitempty"`
+	TLSClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+
+	MTLSEndpointAliases *MTLSEndpointAliases `json:"mtls_endpoint_aliases,omitempty"`
+
+	// DiscoveryURL is the url of the well known document the metadata was
+	// read from
+	DiscoveryURL string `json:"-"`
 }
 
-// GetOIDCWellKnownEndpointsFromIssuerURL gets the well known endpoints for the
-// passed in issuer url
-func GetOIDCWellKnownEndpointsFromIssuerURL(issuerURL string) (*OIDCWellKnownEndpoints, error) {
+// MTLSEndpointAliases holds the alternative endpoints that should be used
+// when the client authenticates using mutual TLS as defined by RFC 8705
+type MTLSEndpointAliases struct {
+	TokenEndpoint                      string `json:"token_endpoint,omitempty"`
+	RevocationEndpoint                 string `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint              string `json:"introspection_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint        string `json:"device_authorization_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint,omitempty"`
+}
+
+// WithMTLSEndpointAliases returns a copy of the endpoints with any mutual TLS
+// endpoint aliases the issuer advertises replacing the regular endpoints
+func (e OIDCWellKnownEndpoints) WithMTLSEndpointAliases() OIDCWellKnownEndpoints {
+	if e.MTLSEndpointAliases == nil {
+		return e
+	}
+
+	if e.MTLSEndpointAliases.TokenEndpoint != "" {
+		e.TokenEndpoint = e.MTLSEndpointAliases.TokenEndpoint
+	}
+
+	if e.MTLSEndpointAliases.RevocationEndpoint != "" {
+		e.RevocationEndpoint = e.MTLSEndpointAliases.RevocationEndpoint
+	}
+
+	if e.MTLSEndpointAliases.IntrospectionEndpoint != "" {
+		e.IntrospectionEndpoint = e.MTLSEndpointAliases.IntrospectionEndpoint
+	}
+
+	if e.MTLSEndpointAliases.DeviceAuthorizationEndpoint != "" {
+		e.DeviceAuthorizationEndpoint = e.MTLSEndpointAliases.DeviceAuthorizationEndpoint
+	}
+
+	if e.MTLSEndpointAliases.PushedAuthorizationRequestEndpoint != "" {
+		e.PushedAuthorizationRequestEndpoint = e.MTLSEndpointAliases.PushedAuthorizationRequestEndpoint
+	}
+
+	return e
+}
+
+// Validate checks that the metadata was published by <issuerURL> and that the
+// endpoints in it can be used. A trailing slash is ignored when comparing the
+// issuer as issuers are commonly configured without it.
+func (e OIDCWellKnownEndpoints) Validate(issuerURL string) error {
+	if e.Issuer == "" {
+		return errors.New("the issuer is missing")
+	}
+
+	if strings.TrimSuffix(e.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return fmt.Errorf("the issuer %q does not match the configured issuer %q", e.Issuer, issuerURL)
+	}
+
+	if e.TokenEndpoint == "" {
+		return errors.New("the token_endpoint is missing")
+	}
+
+	endpoints := []struct{ name, value string }{
+		{"authorization_endpoint", e.AuthorizationEndpoint},
+		{"token_endpoint", e.TokenEndpoint},
+		{"userinfo_endpoint", e.UserinfoEndpoint},
+		{"jwks_uri", e.JWKSURI},
+		{"registration_endpoint", e.RegistrationEndpoint},
+		{"revocation_endpoint", e.RevocationEndpoint},
+		{"introspection_endpoint", e.IntrospectionEndpoint},
+		{"device_authorization_endpoint", e.DeviceAuthorizationEndpoint},
+		{"pushed_authorization_request_endpoint", e.PushedAuthorizationRequestEndpoint},
+		{"end_session_endpoint", e.EndSessionEndpoint},
+	}
+	if e.MTLSEndpointAliases != nil {
+		endpoints = append(endpoints, []struct{ name, value string }{
+			{"mtls_endpoint_aliases.token_endpoint", e.MTLSEndpointAliases.TokenEndpoint},
+			{"mtls_endpoint_aliases.revocation_endpoint", e.MTLSEndpointAliases.RevocationEndpoint},
+			{"mtls_endpoint_aliases.introspection_endpoint", e.MTLSEndpointAliases.IntrospectionEndpoint},
+			{"mtls_endpoint_aliases.device_authorization_endpoint", e.MTLSEndpointAliases.DeviceAuthorizationEndpoint},
+			{"mtls_endpoint_aliases.pushed_authorization_request_endpoint", e.MTLSEndpointAliases.PushedAuthorizationRequestEndpoint},
+		}...)
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			continue
+		}
+
+		if err := validateEndpointURL(endpoint.value); err != nil {
+			return errors.Wrapf(err, "the %s is invalid", endpoint.name)
+		}
+	}
+
+	return nil
+}
+
+// validateEndpointURL checks that the endpoint is an absolute https URL.
+// Plain http is only allowed for loopback addresses.
+func validateEndpointURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%q is not an absolute url", endpoint)
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if isLoopbackHost(u.Hostname()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q does not use https", endpoint)
+}
+
+// isLoopbackHost checks if the host name refers to the local machine
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// wellKnownURLs builds the URLs of the OpenID Connect discovery document and
+// of the RFC 8414 authorization server metadata document for the issuer, in
+// the order they are tried. The OpenID Connect document is appended to the
+// issuer path while the RFC 8414 document is inserted before it.
+func wellKnownURLs(issuerURL string) ([]string, error) {
 	u, err := url.Parse(issuerURL)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not parse issuer url to build well known endpoints")
 	}
-	u.Path = path.Join(u.Path, ".well-known/openid-configuration")
+	issuerPath := strings.TrimSuffix(u.Path, "/")
+
+	oidc := *u
+	oidc.Path = issuerPath + "/.well-known/openid-configuration"
+	oidc.RawPath = ""
+
+	oauth := *u
+	oauth.Path = "/.well-known/oauth-authorization-server" + issuerPath
+	oauth.RawPath = ""
+
+	return []string{oidc.String(), oauth.String()}, nil
+}
+
+// metadataStatusError is returned when getting a document from the issuer,
+// such as a well known document or the jwks, was not successful. It wraps the
+// OAuthError read from the response.
+type metadataStatusError struct {
+	document string
+	url      string
+	err      *OAuthError
+}
+
+func (e *metadataStatusError) Error() string {
+	if e.err.Code == "" {
+		return fmt.Sprintf("could not get %s from url %s: unexpected status code %d", e.document, e.url, e.err.StatusCode)
+	}
+
+	return fmt.Sprintf("could not get %s from url %s: %s", e.document, e.url, e.err)
+}
+
+func (e *metadataStatusError) Unwrap() error {
+	return e.err
+}
+
+// notFound checks if the document does not exist, in which case the next one
+// can be tried. Other statuses, such as a server error, are returned instead
+// of hiding the actual problem behind the next document.
+func (e *metadataStatusError) notFound() bool {
+	return e.err.StatusCode == http.StatusNotFound || e.err.StatusCode == http.StatusGone
+}
+
+// discoveryError is returned when none of the well known documents of an
+// issuer exist. It wraps the error of the last document that was tried.
+type discoveryError struct {
+	issuerURL string
+	notFound  []*metadataStatusError
+}
+
+func (e *discoveryError) Error() string {
+	messages := make([]string, len(e.notFound))
+	for i, err := range e.notFound {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("no well known endpoints found for issuer %s: %s", e.issuerURL, strings.Join(messages, "; "))
+}
+
+func (e *discoveryError) Unwrap() error {
+	return e.notFound[len(e.notFound)-1]
+}
+
+// GetOIDCWellKnownEndpointsFromIssuerURL gets the well known endpoints for the
+// passed in issuer url. The OpenID Connect discovery document is tried first
+// and the RFC 8414 authorization server metadata document is used when it
+// does not exist, that is when the issuer responds with 404 or 410.
+// DiscoveryURL reports which document was used.
+func GetOIDCWellKnownEndpointsFromIssuerURL(issuerURL string) (*OIDCWellKnownEndpoints, error) {
+	return GetOIDCWellKnownEndpointsUsingTransport(issuerURL, &http.Client{})
+}
+
+// GetOIDCWellKnownEndpointsUsingTransport works like
+// GetOIDCWellKnownEndpointsFromIssuerURL but gets the documents using
+// <transport>, which allows them to be cached
+func GetOIDCWellKnownEndpointsUsingTransport(issuerURL string, transport HTTPAuthTransport) (*OIDCWellKnownEndpoints, error) {
+	documentURLs, err := wellKnownURLs(issuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryErr := &discoveryError{issuerURL: issuerURL}
+	for _, documentURL := range documentURLs {
+		wkEndpoints, err := getWellKnownEndpoints(transport, documentURL, issuerURL)
+		if err == nil {
+			return wkEndpoints, nil
+		}
 
-	r, err := http.Get(u.String())
+		statusErr, ok := err.(*metadataStatusError)
+		if !ok || !statusErr.notFound() {
+			return nil, err
+		}
+		discoveryErr.notFound = append(discoveryErr.notFound, statusErr)
+	}
+
+	return nil, discoveryErr
+}
+
+// getWellKnownEndpoints gets and validates the well known document at
+// <documentURL>
+func getWellKnownEndpoints(transport HTTPAuthTransport, documentURL, issuerURL string) (*OIDCWellKnownEndpoints, error) {
+	request, err := http.NewRequest("GET", documentURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build well known endpoints request")
+	}
+
+	r, err := transport.Do(request)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not get well known endpoints from url %s", u.String())
+		return nil, errors.Wrapf(err, "could not get well known endpoints from url %s", documentURL)
 	}
+
+	if r.StatusCode != http.StatusOK {
+		return nil, &metadataStatusError{document: "well known endpoints", url: documentURL, err: newOAuthError(r)}
+	}
+	defer r.Body.Close()
+
 	var wkEndpoints OIDCWellKnownEndpoints
 	err = json.NewDecoder(r.Body).Decode(&wkEndpoints)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not decode json body when getting well known endpoints")
 	}
 
+	if err := wkEndpoints.Validate(issuerURL); err != nil {
+		return nil, errors.Wrapf(err, "invalid well known endpoints from url %s", documentURL)
+	}
+	wkEndpoints.DiscoveryURL = documentURL
+
 	return &wkEndpoints, nil
 }
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 
@@ -12,26 +13,143 @@ import (
 var _ = Describe("GetOIDCWellKnownEndpointsFromIssuerURL", func() {
 	It("calls and gets the well known data from the correct endpoint for the issuer", func() {
 		var req *http.Request
-		wkEndpointsResp := OIDCWellKnownEndpoints{AuthorizationEndpoint: "the-auth-endpoint", TokenEndpoint: "the-token-endpoint"}
-		responseBytes, err := json.Marshal(wkEndpointsResp)
-		Expect(err).ToNot(HaveOccurred())
+		var wkEndpointsResp OIDCWellKnownEndpoints
 
 		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			req = r
 
+			responseBytes, err := json.Marshal(wkEndpointsResp)
+			Expect(err).ToNot(HaveOccurred())
 			w.WriteHeader(http.StatusOK)
 			w.Write(responseBytes)
 
 		}))
 		defer ts.Close()
+		wkEndpointsResp = OIDCWellKnownEndpoints{
+			Issuer:                ts.URL + "/",
+			AuthorizationEndpoint: ts.URL + "/authorize",
+			TokenEndpoint:         ts.URL + "/token",
+			JWKSURI:               ts.URL + "/jwks",
+			ScopesSupported:       []string{"openid", "email"},
+		}
 
 		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
 
 		Expect(err).ToNot(HaveOccurred())
+		wkEndpointsResp.DiscoveryURL = ts.URL + "/.well-known/openid-configuration"
 		Expect(*endpoints).To(Equal(wkEndpointsResp))
 		Expect(req.URL.Path).To(Equal("/.well-known/openid-configuration"))
 	})
 
+	It("falls back to the authorization server metadata inserted before the issuer path", func() {
+		var paths []string
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			if r.URL.Path != "/.well-known/oauth-authorization-server/tenant/one" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.Write([]byte(`{"issuer":"http://` + r.Host + `/tenant/one","token_endpoint":"http://` + r.Host + `/tenant/one/token"}`))
+		}))
+		defer ts.Close()
+
+		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL + "/tenant/one/")
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoints.TokenEndpoint).To(Equal(ts.URL + "/tenant/one/token"))
+		Expect(endpoints.DiscoveryURL).To(Equal(ts.URL + "/.well-known/oauth-authorization-server/tenant/one"))
+		Expect(paths).To(Equal([]string{
+			"/tenant/one/.well-known/openid-configuration",
+			"/.well-known/oauth-authorization-server/tenant/one",
+		}))
+	})
+
+	It("errors when the response is not successful", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer ts.Close()
+
+		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
+
+		Expect(err.Error()).To(Equal("no well known endpoints found for issuer " + ts.URL + ": " +
+			"could not get well known endpoints from url " + ts.URL + "/.well-known/openid-configuration: unexpected status code 404; " +
+			"could not get well known endpoints from url " + ts.URL + "/.well-known/oauth-authorization-server: unexpected status code 404"))
+		Expect(endpoints).To(BeNil())
+	})
+
+	It("returns the OAuthError sent by the issuer", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":"access_denied","error_description":"blocked"}`))
+		}))
+		defer ts.Close()
+
+		_, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
+
+		var oauthErr *OAuthError
+		Expect(errors.As(err, &oauthErr)).To(BeTrue())
+		Expect(oauthErr.Code).To(Equal("access_denied"))
+		Expect(oauthErr.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(err.Error()).To(Equal("could not get well known endpoints from url " + ts.URL + "/.well-known/openid-configuration: access_denied: blocked"))
+	})
+
+	It("falls back when the discovery document is gone", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/.well-known/openid-configuration" {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+
+			w.Write([]byte(`{"issuer":"http://` + r.Host + `","token_endpoint":"http://` + r.Host + `/token"}`))
+		}))
+		defer ts.Close()
+
+		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoints.DiscoveryURL).To(Equal(ts.URL + "/.well-known/oauth-authorization-server"))
+	})
+
+	It("does not fall back when the issuer fails to serve the discovery document", func() {
+		var paths []string
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			if r.URL.Path == "/.well-known/openid-configuration" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write([]byte(`{"issuer":"http://` + r.Host + `","token_endpoint":"http://` + r.Host + `/token"}`))
+		}))
+		defer ts.Close()
+
+		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
+
+		var oauthErr *OAuthError
+		Expect(errors.As(err, &oauthErr)).To(BeTrue())
+		Expect(oauthErr.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(err.Error()).To(Equal("could not get well known endpoints from url " + ts.URL + "/.well-known/openid-configuration: unexpected status code 503"))
+		Expect(endpoints).To(BeNil())
+		Expect(paths).To(Equal([]string{"/.well-known/openid-configuration"}))
+	})
+
+	It("errors when the issuer does not match", func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"issuer":"https://attacker","token_endpoint":"https://attacker/token"}`))
+		}))
+		defer ts.Close()
+
+		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(ts.URL)
+
+		Expect(err.Error()).To(Equal("invalid well known endpoints from url " + ts.URL + `/.well-known/openid-configuration: the issuer "https://attacker" does not match the configured issuer "` + ts.URL + `"`))
+		Expect(endpoints).To(BeNil())
+	})
+
 	It("errors when url.Parse errors", func() {
 		endpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL("://")
 
@@ -67,4 +185,78 @@ var _ = Describe("GetOIDCWellKnownEndpointsFromIssuerURL", func() {
 		Expect(endpoints).To(BeNil())
 		Expect(req.URL.Path).To(Equal("/.well-known/openid-configuration"))
 	})
+
+	Describe("Validate", func() {
+		var endpoints OIDCWellKnownEndpoints
+
+		BeforeEach(func() {
+			endpoints = OIDCWellKnownEndpoints{
+				Issuer:                "https://issuer/",
+				AuthorizationEndpoint: "https://issuer/authorize",
+				TokenEndpoint:         "https://issuer/token",
+			}
+		})
+
+		It("accepts valid metadata", func() {
+			Expect(endpoints.Validate("https://issuer")).To(Succeed())
+		})
+
+		It("requires the issuer", func() {
+			endpoints.Issuer = ""
+
+			Expect(endpoints.Validate("https://issuer").Error()).To(Equal("the issuer is missing"))
+		})
+
+		It("requires the token endpoint", func() {
+			endpoints.TokenEndpoint = ""
+
+			Expect(endpoints.Validate("https://issuer").Error()).To(Equal("the token_endpoint is missing"))
+		})
+
+		It("requires endpoints to use https", func() {
+			endpoints.JWKSURI = "http://issuer/jwks"
+
+			Expect(endpoints.Validate("https://issuer").Error()).To(Equal(`the jwks_uri is invalid: "http://issuer/jwks" does not use https`))
+		})
+
+		It("allows http for loopback addresses", func() {
+			endpoints.Issuer = "http://127.0.0.1:8080"
+			endpoints.TokenEndpoint = "http://localhost:8080/token"
+			endpoints.AuthorizationEndpoint = "http://127.0.0.1:8080/authorize"
+
+			Expect(endpoints.Validate("http://127.0.0.1:8080")).To(Succeed())
+		})
+
+		It("requires absolute urls", func() {
+			endpoints.MTLSEndpointAliases = &MTLSEndpointAliases{TokenEndpoint: "/token"}
+
+			Expect(endpoints.Validate("https://issuer").Error()).To(Equal(`the mtls_endpoint_aliases.token_endpoint is invalid: "/token" is not an absolute url`))
+		})
+	})
+
+	Describe("WithMTLSEndpointAliases", func() {
+		It("replaces the endpoints with the advertised aliases", func() {
+			endpoints := OIDCWellKnownEndpoints{
+				AuthorizationEndpoint:       "https://issuer/authorize",
+				TokenEndpoint:               "https://issuer/token",
+				DeviceAuthorizationEndpoint: "https://issuer/device",
+				MTLSEndpointAliases: &MTLSEndpointAliases{
+					TokenEndpoint: "https://mtls.issuer/token",
+				},
+			}
+
+			aliased := endpoints.WithMTLSEndpointAliases()
+
+			Expect(aliased.AuthorizationEndpoint).To(Equal("https://issuer/authorize"))
+			Expect(aliased.TokenEndpoint).To(Equal("https://mtls.issuer/token"))
+			Expect(aliased.DeviceAuthorizationEndpoint).To(Equal("https://issuer/device"))
+			Expect(endpoints.TokenEndpoint).To(Equal("https://issuer/token"))
+		})
+
+		It("keeps the endpoints when no aliases are advertised", func() {
+			endpoints := OIDCWellKnownEndpoints{TokenEndpoint: "https://issuer/token"}
+
+			Expect(endpoints.WithMTLSEndpointAliases()).To(Equal(endpoints))
+		})
+	})
 })
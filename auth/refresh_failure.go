@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// RefreshFailure classifies why refreshing the tokens failed
+type RefreshFailure string
+
+const (
+	// RefreshRejected means the issuer rejected the refresh token with
+	// invalid_grant because it expired, was revoked or was reused
+	RefreshRejected RefreshFailure = "the refresh token was rejected"
+	// RefreshUnavailable means the issuer could not be reached, timed out,
+	// was rate limiting or responded with a server error. Trying again later
+	// may work.
+	RefreshUnavailable RefreshFailure = "the issuer is unavailable"
+	// RefreshFailed means refreshing failed for any other reason
+	RefreshFailed RefreshFailure = "refreshing failed"
+	// RefreshNotSupported means the IssuerTokenProvider does not refresh
+	// tokens and always authenticates instead
+	RefreshNotSupported RefreshFailure = "refreshing is not supported"
+)
+
+// refreshNotSupportedError is returned by IssuerTokenProviders that cannot
+// use refresh tokens
+type refreshNotSupportedError string
+
+func (e refreshNotSupportedError) Error() string {
+	return string(e)
+}
+
+// ClassifyRefreshError works out the RefreshFailure for an error returned
+// when refreshing the tokens
+func ClassifyRefreshError(err error) RefreshFailure {
+	if errors.Is(err, ErrInvalidGrant) {
+		return RefreshRejected
+	}
+
+	var notSupported refreshNotSupportedError
+	if errors.As(err, &notSupported) {
+		return RefreshNotSupported
+	}
+
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) && oauthErr.Temporary() {
+		return RefreshUnavailable
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return RefreshUnavailable
+	}
+
+	return RefreshFailed
+}
+
+// RefreshFailurePolicy decides which refresh failures fall back to
+// authenticating again, which is interactive for the browser and device
+// flows
+type RefreshFailurePolicy string
+
+const (
+	// ReauthenticateOnInvalidGrant only authenticates again when the refresh
+	// token was rejected
+	ReauthenticateOnInvalidGrant RefreshFailurePolicy = "invalid-grant"
+	// ReauthenticateOnNonTransient also authenticates again for failures
+	// that are not caused by the issuer being unavailable. It is the default
+	// so that a refresh token that keeps failing is replaced.
+	ReauthenticateOnNonTransient RefreshFailurePolicy = "non-transient"
+	// ReauthenticateOnAny authenticates again whatever made refreshing fail
+	ReauthenticateOnAny RefreshFailurePolicy = "any"
+)
+
+// ParseRefreshFailurePolicy checks that <policy> is a known
+// RefreshFailurePolicy. An empty policy is the default.
+func ParseRefreshFailurePolicy(policy string) (RefreshFailurePolicy, error) {
+	switch RefreshFailurePolicy(policy) {
+	case "":
+		return ReauthenticateOnNonTransient, nil
+	case ReauthenticateOnInvalidGrant, ReauthenticateOnNonTransient, ReauthenticateOnAny:
+		return RefreshFailurePolicy(policy), nil
+	}
+
+	return "", fmt.Errorf("unknown refresh failure policy %q, use %s, %s or %s", policy, ReauthenticateOnInvalidGrant, ReauthenticateOnNonTransient, ReauthenticateOnAny)
+}
+
+// reauthenticates checks if the policy allows authenticating again after the
+// refresh failure. Refresh tokens that were rejected or cannot be used are
+// always replaced by authenticating again. An empty policy is the default.
+func (p RefreshFailurePolicy) reauthenticates(failure RefreshFailure) bool {
+	switch failure {
+	case RefreshRejected, RefreshNotSupported:
+		return true
+	case RefreshUnavailable:
+		return p == ReauthenticateOnAny
+	}
+
+	return p != ReauthenticateOnInvalidGrant
+}
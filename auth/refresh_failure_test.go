@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"net"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	pkgerrors "github.com/pkg/errors"
+)
+
+var _ = Describe("ClassifyRefreshError", func() {
+	It("classifies rejected refresh tokens", func() {
+		Expect(ClassifyRefreshError(pkgerrors.Wrap(ErrInvalidGrant, "could not refresh"))).To(Equal(RefreshRejected))
+		Expect(ClassifyRefreshError(&OAuthError{Code: "invalid_grant", StatusCode: 400})).To(Equal(RefreshRejected))
+	})
+
+	It("classifies transport errors and timeouts as unavailable", func() {
+		err := &url.Error{Op: "Post", URL: "https://issuer/oauth/token", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+
+		Expect(ClassifyRefreshError(err)).To(Equal(RefreshUnavailable))
+	})
+
+	It("classifies rate limiting as unavailable", func() {
+		Expect(ClassifyRefreshError(&OAuthError{StatusCode: 429})).To(Equal(RefreshUnavailable))
+	})
+
+	It("classifies server errors as unavailable", func() {
+		Expect(ClassifyRefreshError(&OAuthError{StatusCode: 502})).To(Equal(RefreshUnavailable))
+		Expect(ClassifyRefreshError(&OAuthError{Code: "temporarily_unavailable", StatusCode: 400})).To(Equal(RefreshUnavailable))
+	})
+
+	It("classifies other status codes and errors as failed", func() {
+		Expect(ClassifyRefreshError(&OAuthError{StatusCode: 403})).To(Equal(RefreshFailed))
+		Expect(ClassifyRefreshError(errors.New("uh oh"))).To(Equal(RefreshFailed))
+	})
+
+	It("classifies providers that cannot refresh", func() {
+		Expect(ClassifyRefreshError(refreshNotSupportedError("not supported"))).To(Equal(RefreshNotSupported))
+	})
+})
+
+var _ = Describe("RefreshFailurePolicy", func() {
+	It("defaults to authenticating again for non-transient failures", func() {
+		policy, err := ParseRefreshFailurePolicy("")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(ReauthenticateOnNonTransient))
+		Expect(RefreshFailurePolicy("").reauthenticates(RefreshFailed)).To(BeTrue())
+		Expect(RefreshFailurePolicy("").reauthenticates(RefreshUnavailable)).To(BeFalse())
+	})
+
+	It("only authenticates again for rejected refresh tokens on invalid-grant", func() {
+		Expect(ReauthenticateOnInvalidGrant.reauthenticates(RefreshRejected)).To(BeTrue())
+		Expect(ReauthenticateOnInvalidGrant.reauthenticates(RefreshFailed)).To(BeFalse())
+		Expect(ReauthenticateOnInvalidGrant.reauthenticates(RefreshUnavailable)).To(BeFalse())
+	})
+
+	It("authenticates again for non-transient failures", func() {
+		Expect(ReauthenticateOnNonTransient.reauthenticates(RefreshFailed)).To(BeTrue())
+		Expect(ReauthenticateOnNonTransient.reauthenticates(RefreshUnavailable)).To(BeFalse())
+	})
+
+	It("authenticates again for any failure", func() {
+		Expect(ReauthenticateOnAny.reauthenticates(RefreshUnavailable)).To(BeTrue())
+	})
+
+	It("always authenticates when refreshing is not supported", func() {
+		Expect(ReauthenticateOnInvalidGrant.reauthenticates(RefreshNotSupported)).To(BeTrue())
+	})
+
+	It("errors for unknown policies", func() {
+		_, err := ParseRefreshFailurePolicy("sometimes")
+
+		Expect(err.Error()).To(Equal(`unknown refresh failure policy "sometimes", use invalid-grant, non-transient or any`))
+	})
+})
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryTimeout is how long a request is retried for when no timeout is
+// configured
+const DefaultRetryTimeout = 30 * time.Second
+
+const (
+	initialRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 8 * time.Second
+)
+
+// noRetryKey marks requests in their context that must never be sent twice
+type noRetryKey struct{}
+
+// withoutRetries marks the request so that RetryTransport sends it only once
+func withoutRetries(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), noRetryKey{}, true))
+}
+
+// beforeRetryKey holds the function RetryTransport calls on each copy of a
+// request before sending it again
+type beforeRetryKey struct{}
+
+// withBeforeRetry has RetryTransport call <prepare> on every copy of the
+// request it sends again, for example to replace a DPoP proof that can only
+// be used once
+func withBeforeRetry(request *http.Request, prepare func(*http.Request) error) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), beforeRetryKey{}, prepare))
+}
+
+// RetryTransport satisfies the HTTPAuthTransport interface and retries
+// requests the issuer could not handle right now with exponential backoff and
+// jitter. Retry-After is honoured and no more retries are made once the
+// timeout would be exceeded.
+//
+// Only requests that are safe to send again are retried. GET and HEAD
+// requests are retried on 429, 502, 503 and 504 responses and on network
+// errors. Other requests are only retried on 429 and 503 responses, which
+// tell that the request was not handled, and never when they were marked with
+// withoutRetries, such as the exchange of an authorization code. Requests
+// marked with withBeforeRetry are prepared again before each retry.
+type RetryTransport struct {
+	transport HTTPAuthTransport
+	timeout   time.Duration
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+	jitter    func(d time.Duration) time.Duration
+}
+
+// NewRetryTransport builds a RetryTransport that sends requests using
+// <transport> and keeps retrying them for up to <timeout>.
+// DefaultRetryTimeout is used when <timeout> is zero.
+func NewRetryTransport(transport HTTPAuthTransport, timeout time.Duration) *RetryTransport {
+	if timeout == 0 {
+		timeout = DefaultRetryTimeout
+	}
+
+	return &RetryTransport{
+		transport: transport,
+		timeout:   timeout,
+		now:       time.Now,
+		sleep:     sleepContext,
+		jitter:    fullJitter,
+	}
+}
+
+// Do sends the request and retries it while it is allowed to
+func (t *RetryTransport) Do(request *http.Request) (*http.Response, error) {
+	if !isReplayable(request) {
+		return t.transport.Do(request)
+	}
+
+	deadline := t.now().Add(t.timeout)
+	backoff := initialRetryBackoff
+
+	for {
+		response, err := t.transport.Do(request)
+		if !shouldRetry(request, response, err) {
+			return response, err
+		}
+
+		delay := t.jitter(backoff)
+		if retryAfter, ok := parseRetryAfter(response, t.now()); ok {
+			delay = retryAfter
+		}
+
+		if t.now().Add(delay).After(deadline) {
+			return response, err
+		}
+
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		if err := t.sleep(request.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		request, err = rewindRequest(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if prepare, ok := request.Context().Value(beforeRetryKey{}).(func(*http.Request) error); ok {
+			if err := prepare(request); err != nil {
+				return nil, err
+			}
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// isReplayable checks if the request can be sent more than once
+func isReplayable(request *http.Request) bool {
+	if noRetry, _ := request.Context().Value(noRetryKey{}).(bool); noRetry {
+		return false
+	}
+
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// isIdempotent checks if sending the request twice has the same effect as
+// sending it once
+func isIdempotent(request *http.Request) bool {
+	return request.Method == http.MethodGet || request.Method == http.MethodHead
+}
+
+// shouldRetry checks if the response or error are worth retrying for the
+// request
+func shouldRetry(request *http.Request, response *http.Response, err error) bool {
+	if err != nil {
+		return request.Context().Err() == nil && isIdempotent(request)
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(request)
+	}
+
+	return false
+}
+
+// parseRetryAfter reads the delay from the Retry-After header, which is either
+// a number of seconds or an HTTP date
+func parseRetryAfter(response *http.Response, now time.Time) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+
+	retryAfter := response.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// rewindRequest copies the request with a fresh body so that it can be sent
+// again
+func rewindRequest(request *http.Request) (*http.Request, error) {
+	retry := request.Clone(request.Context())
+	if request.GetBody == nil {
+		return retry, nil
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry.Body = body
+
+	return retry, nil
+}
+
+// fullJitter picks a random delay between zero and <backoff> so that clients
+// that failed at the same time do not retry at the same time
+func fullJitter(backoff time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// sleepContext waits for <d> or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
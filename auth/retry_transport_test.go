@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryTransport", func() {
+	var now time.Time
+	var delays []time.Duration
+
+	newRetryTransport := func(transport HTTPAuthTransport, timeout time.Duration) *RetryTransport {
+		retry := NewRetryTransport(transport, timeout)
+		retry.now = func() time.Time { return now }
+		retry.sleep = func(ctx context.Context, d time.Duration) error {
+			delays = append(delays, d)
+			now = now.Add(d)
+			return nil
+		}
+		retry.jitter = func(d time.Duration) time.Duration { return d }
+		return retry
+	}
+
+	newPost := func() *http.Request {
+		request, err := newFormRequest("https://issuer/oauth/token", url.Values{"grant_type": []string{"refresh_token"}})
+		Expect(err).NotTo(HaveOccurred())
+		return request
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		delays = nil
+	})
+
+	It("retries with exponential backoff until the request succeeds", func() {
+		transport := &mockTransport{
+			Responses: []*http.Response{buildResponse(503, nil), buildResponse(429, nil), buildResponse(503, nil)},
+			Response:  buildResponse(200, "ok"),
+		}
+		request := newPost()
+
+		response, err := newRetryTransport(transport, time.Minute).Do(request)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(200))
+		Expect(delays).To(Equal([]time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}))
+		Expect(transport.Requests).To(HaveLen(4))
+		for _, sent := range transport.Requests {
+			body, err := ioutil.ReadAll(sent.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("grant_type=refresh_token"))
+		}
+	})
+
+	It("waits as long as Retry-After asks for", func() {
+		transport := &mockTransport{
+			Responses: []*http.Response{
+				buildResponseWithHeader(429, nil, http.Header{"Retry-After": []string{"3"}}),
+				buildResponseWithHeader(503, nil, http.Header{"Retry-After": []string{now.Add(5 * time.Second).Format(http.TimeFormat)}}),
+			},
+			Response: buildResponse(200, "ok"),
+		}
+
+		_, err := newRetryTransport(transport, time.Minute).Do(newPost())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delays).To(Equal([]time.Duration{3 * time.Second, 2 * time.Second}))
+	})
+
+	It("returns the last response once the timeout would be exceeded", func() {
+		transport := &mockTransport{Response: buildResponse(503, nil)}
+
+		response, err := newRetryTransport(transport, 2*time.Second).Do(newPost())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(503))
+		Expect(delays).To(Equal([]time.Duration{500 * time.Millisecond, time.Second}))
+	})
+
+	It("does not wait for a Retry-After beyond the timeout", func() {
+		transport := &mockTransport{Response: buildResponseWithHeader(429, nil, http.Header{"Retry-After": []string{"120"}})}
+
+		response, err := newRetryTransport(transport, time.Minute).Do(newPost())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(429))
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("retries network errors and gateway errors only for GET requests", func() {
+		request, err := http.NewRequest("GET", "https://issuer/.well-known/openid-configuration", nil)
+		Expect(err).NotTo(HaveOccurred())
+		transport := &mockTransport{Responses: []*http.Response{buildResponse(502, nil)}, Response: buildResponse(200, "ok")}
+
+		_, err = newRetryTransport(transport, time.Minute).Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(transport.Requests).To(HaveLen(2))
+
+		transport = &mockTransport{Response: buildResponse(504, nil)}
+		response, err := newRetryTransport(transport, time.Minute).Do(newPost())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(504))
+		Expect(transport.Requests).To(HaveLen(1))
+
+		transport = &mockTransport{Error: errors.New("connection reset")}
+		_, err = newRetryTransport(transport, time.Minute).Do(newPost())
+		Expect(err).To(MatchError("connection reset"))
+		Expect(transport.Requests).To(HaveLen(1))
+
+		_, err = newRetryTransport(transport, 2*time.Second).Do(request)
+		Expect(err).To(MatchError("connection reset"))
+		Expect(transport.Requests).To(HaveLen(4))
+	})
+
+	It("does not retry other status codes", func() {
+		transport := &mockTransport{Response: buildResponse(400, tokenErrorResponse{Error: "invalid_grant"})}
+
+		response, err := newRetryTransport(transport, time.Minute).Do(newPost())
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(400))
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("never retries requests marked without retries", func() {
+		transport := &mockTransport{Response: buildResponse(503, nil)}
+
+		response, err := newRetryTransport(transport, time.Minute).Do(withoutRetries(newPost()))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(503))
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("does not retry requests whose body cannot be sent again", func() {
+		request, err := http.NewRequest("POST", "https://issuer/oauth/token", ioutil.NopCloser(strings.NewReader("body")))
+		Expect(err).NotTo(HaveOccurred())
+		transport := &mockTransport{Response: buildResponse(503, nil)}
+
+		newRetryTransport(transport, time.Minute).Do(request)
+
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("stops when the request is cancelled while waiting", func() {
+		transport := &mockTransport{Response: buildResponse(503, nil)}
+		retry := newRetryTransport(transport, time.Minute)
+		retry.sleep = sleepContext
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := retry.Do(newPost().WithContext(ctx))
+
+		Expect(err).To(Equal(context.Canceled))
+		Expect(transport.Requests).To(HaveLen(1))
+	})
+
+	It("uses the default timeout when none is set", func() {
+		Expect(NewRetryTransport(&mockTransport{}, 0).timeout).To(Equal(DefaultRetryTimeout))
+	})
+})
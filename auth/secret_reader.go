@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SecretReader is used to read a secret, such as a client secret, only at the
+// point that it is needed
+type SecretReader func() (string, error)
+
+// NewEnvSecretReader builds a SecretReader that reads the secret from the
+// environment variable <name>
+func NewEnvSecretReader(name string) SecretReader {
+	return func() (string, error) {
+		secret, ok := os.LookupEnv(name)
+		if !ok || secret == "" {
+			return "", errors.Errorf("environment variable %s is not set", name)
+		}
+
+		return secret, nil
+	}
+}
+
+// NewFileSecretReader builds a SecretReader that reads the secret from the
+// file at <path>. Surrounding whitespace is trimmed from the file contents.
+func NewFileSecretReader(path string) SecretReader {
+	return func() (string, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not read secret from file %s", path)
+		}
+
+		secret := strings.TrimSpace(string(b))
+		if secret == "" {
+			return "", errors.Errorf("secret file %s is empty", path)
+		}
+
+		return secret, nil
+	}
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SecretReader", func() {
+	Describe("NewEnvSecretReader", func() {
+		AfterEach(func() {
+			os.Unsetenv("PIXY_TEST_SECRET")
+		})
+
+		It("reads the secret from the environment", func() {
+			os.Setenv("PIXY_TEST_SECRET", "shh")
+
+			secret, err := NewEnvSecretReader("PIXY_TEST_SECRET")()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret).To(Equal("shh"))
+		})
+
+		It("errors when the environment variable is not set", func() {
+			secret, err := NewEnvSecretReader("PIXY_TEST_SECRET")()
+
+			Expect(secret).To(BeEmpty())
+			Expect(err.Error()).To(Equal("environment variable PIXY_TEST_SECRET is not set"))
+		})
+	})
+
+	Describe("NewFileSecretReader", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "pixy-secret")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("reads the trimmed secret from the file", func() {
+			path := filepath.Join(dir, "secret")
+			Expect(ioutil.WriteFile(path, []byte("shh\n"), 0600)).To(Succeed())
+
+			secret, err := NewFileSecretReader(path)()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret).To(Equal("shh"))
+		})
+
+		It("errors when the file is empty", func() {
+			path := filepath.Join(dir, "secret")
+			Expect(ioutil.WriteFile(path, []byte("\n"), 0600)).To(Succeed())
+
+			secret, err := NewFileSecretReader(path)()
+
+			Expect(secret).To(BeEmpty())
+			Expect(err.Error()).To(Equal("secret file " + path + " is empty"))
+		})
+
+		It("errors when the file cannot be read", func() {
+			secret, err := NewFileSecretReader(filepath.Join(dir, "missing"))()
+
+			Expect(secret).To(BeEmpty())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package auth
+
+import "github.com/pkg/errors"
+
+// TokenExchanger abstracts exchanging a token for a token with a different
+// audience
+type TokenExchanger interface {
+	ExchangeToken(req TokenExchangeRequest) (*TokenResult, error)
+}
+
+// SubjectTokenProvider abstracts getting the token that is exchanged for a
+// token with a different audience. A CachingTokenProvider for the hub
+// audience satisfies it.
+type SubjectTokenProvider interface {
+	GetAccessToken() (string, error)
+}
+
+// TokenExchangeProvider satisfies the IssuerTokenProvider interface and gets
+// tokens for an audience by exchanging a token that was issued for a hub
+// audience. This allows a single login to be used for many audiences.
+type TokenExchangeProvider struct {
+	issuerData         Issuer
+	requestedTokenType string
+	subject            SubjectTokenProvider
+	exchanger          TokenExchanger
+}
+
+// NewTokenExchangeProvider allows for the easy setup of TokenExchangeProvider.
+// <requestedTokenType> should be one of TokenTypeAccessToken or
+// TokenTypeIDToken.
+func NewTokenExchangeProvider(
+	issuerData Issuer,
+	requestedTokenType string,
+	subject SubjectTokenProvider,
+	exchanger TokenExchanger) *TokenExchangeProvider {
+	return &TokenExchangeProvider{
+		issuerData:         issuerData,
+		requestedTokenType: requestedTokenType,
+		subject:            subject,
+		exchanger:          exchanger,
+	}
+}
+
+// NewDefaultTokenExchangeProvider provides an easy way to build up a default
+// token exchange provider with all the correct configuration
+func NewDefaultTokenExchangeProvider(issuerData Issuer, requestedTokenType string, subject SubjectTokenProvider, options ClientOptions) (*TokenExchangeProvider, error) {
+	tokenRetriever, _, err := newDefaultTokenRetriever(issuerData, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTokenExchangeProvider(
+		issuerData,
+		requestedTokenType,
+		subject,
+		tokenRetriever), nil
+}
+
+// Authenticate gets the hub audience access token, authenticating for it if
+// needed, and exchanges it for a token for the issuer audience
+func (p *TokenExchangeProvider) Authenticate() (*TokenResult, error) {
+	subjectToken, err := p.subject.GetAccessToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get subject token")
+	}
+
+	tokenResult, err := p.exchanger.ExchangeToken(TokenExchangeRequest{
+		ClientID:           p.issuerData.ClientID,
+		SubjectToken:       subjectToken,
+		SubjectTokenType:   TokenTypeAccessToken,
+		RequestedTokenType: p.requestedTokenType,
+		Audience:           p.issuerData.Audience,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not exchange token")
+	}
+
+	return tokenResult, nil
+}
+
+// FromRefreshToken always returns an error as exchanged tokens are renewed by
+// exchanging the hub audience token again
+func (p *TokenExchangeProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
+	return nil, refreshNotSupportedError("refresh tokens are not supported for exchanged tokens")
+}
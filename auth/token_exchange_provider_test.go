@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockTokenExchanger struct {
+	CalledWithRequest *TokenExchangeRequest
+	ReturnsTokens     *TokenResult
+	ReturnsError      error
+}
+
+func (m *mockTokenExchanger) ExchangeToken(req TokenExchangeRequest) (*TokenResult, error) {
+	m.CalledWithRequest = &req
+	return m.ReturnsTokens, m.ReturnsError
+}
+
+type mockSubjectTokenProvider struct {
+	ReturnsToken string
+	ReturnsError error
+}
+
+func (m *mockSubjectTokenProvider) GetAccessToken() (string, error) {
+	return m.ReturnsToken, m.ReturnsError
+}
+
+var _ = Describe("TokenExchangeProvider", func() {
+	issuer := Issuer{
+		IssuerEndpoint: "http://issuer",
+		ClientID:       "test_clientID",
+		Audience:       "cluster_audience",
+	}
+
+	var exchanger *mockTokenExchanger
+	var subject *mockSubjectTokenProvider
+	var provider *TokenExchangeProvider
+
+	BeforeEach(func() {
+		exchanger = &mockTokenExchanger{
+			ReturnsTokens: &TokenResult{AccessToken: "clusterToken"},
+		}
+		subject = &mockSubjectTokenProvider{ReturnsToken: "hubToken"}
+		provider = NewTokenExchangeProvider(issuer, TokenTypeAccessToken, subject, exchanger)
+	})
+
+	It("exchanges the hub token for a token for the audience", func() {
+		tokens, err := provider.Authenticate()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokens).To(Equal(exchanger.ReturnsTokens))
+		Expect(exchanger.CalledWithRequest).To(Equal(&TokenExchangeRequest{
+			ClientID:           "test_clientID",
+			SubjectToken:       "hubToken",
+			SubjectTokenType:   TokenTypeAccessToken,
+			RequestedTokenType: TokenTypeAccessToken,
+			Audience:           "cluster_audience",
+		}))
+	})
+
+	It("requests the configured token type", func() {
+		provider = NewTokenExchangeProvider(issuer, TokenTypeIDToken, subject, exchanger)
+
+		provider.Authenticate()
+
+		Expect(exchanger.CalledWithRequest.RequestedTokenType).To(Equal(TokenTypeIDToken))
+	})
+
+	It("returns an error when the subject token cannot be retrieved", func() {
+		subject.ReturnsError = errors.New("someerror")
+
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not get subject token: someerror"))
+		Expect(exchanger.CalledWithRequest).To(BeNil())
+	})
+
+	It("returns an error when the exchange fails", func() {
+		exchanger.ReturnsError = errors.New("someerror")
+
+		tokens, err := provider.Authenticate()
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("could not exchange token: someerror"))
+	})
+
+	It("does not support refresh tokens", func() {
+		tokens, err := provider.FromRefreshToken("refresh")
+
+		Expect(tokens).To(BeNil())
+		Expect(err.Error()).To(Equal("refresh tokens are not supported for exchanged tokens"))
+	})
+})
@@ -1,20 +1,26 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/tls"
 	"net/http"
+	goos "os"
+	"time"
 
 	"github.com/auth0/k8s-pixy-auth/os"
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 )
 
 // TokenProvider takes care of the mechanics needed for getting an access
 // Token
 type TokenProvider struct {
-	allowRefresh bool
-	issuerData   Issuer
-	codeProvider AuthorizationCodeProvider
-	exchanger    AuthorizationTokenExchanger
-	challenger   Challenger
+	allowRefresh   bool
+	issuerData     Issuer
+	codeProvider   AuthorizationCodeProvider
+	deviceProvider DeviceAuthorizationProvider
+	exchanger      AuthorizationTokenExchanger
+	challenger     Challenger
 }
 
 // AuthorizationCodeProvider abstracts getting an authorization code
@@ -22,6 +28,12 @@ type AuthorizationCodeProvider interface {
 	GetCode(challenge Challenge, additionalScopes ...string) (*AuthorizationCodeResult, error)
 }
 
+// DeviceAuthorizationProvider abstracts getting tokens using the device
+// authorization grant
+type DeviceAuthorizationProvider interface {
+	GetTokens(additionalScopes ...string) (*TokenResult, error)
+}
+
 // AuthorizationTokenExchanger abstracts exchanging for tokens
 type AuthorizationTokenExchanger interface {
 	ExchangeCode(req AuthorizationCodeExchangeRequest) (*TokenResult, error)
@@ -34,6 +46,18 @@ type TokenResult struct {
 	IDToken      string `json:"id_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int    `json:"expires_in"`
+	// ExpiresAt is when the access token expires in seconds since the epoch.
+	// It is worked out from ExpiresIn when the tokens are received.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// TokenType is the type of the access token, either Bearer or DPoP
+	TokenType string `json:"token_type,omitempty"`
+	// CertificateThumbprint is the thumbprint of the client certificate the
+	// tokens are bound to
+	CertificateThumbprint string `json:"x5t#S256,omitempty"`
+	// ClockSkew is how many seconds the issuer's clock was ahead of the local
+	// clock when the tokens were received, according to the Date header of
+	// the token response
+	ClockSkew int64 `json:"clock_skew,omitempty"`
 }
 
 // Issuer holds information about the issuer of tokens
@@ -43,6 +67,144 @@ type Issuer struct {
 	Audience       string
 }
 
+// ClientOptions holds the optional configuration used when the client talks
+// to the issuer
+type ClientOptions struct {
+	// ClientAuthMethod forces the client authentication method to use. When it
+	// is empty the method is picked from the methods the issuer supports.
+	ClientAuthMethod string
+	// ClientSecret reads the client secret for confidential clients
+	ClientSecret SecretReader
+	// ClientKey reads the PEM encoded private key for private_key_jwt
+	ClientKey SecretReader
+	// ClientCertificate is presented to the issuer for mutual TLS client
+	// authentication and certificate-bound tokens
+	ClientCertificate *tls.Certificate
+	// DPoPKey is used to sign DPoP proofs so that tokens are bound to it
+	DPoPKey *ecdsa.PrivateKey
+	// RequirePushedAuthorizationRequests fails the browser flow when the
+	// authorization request cannot be pushed to the issuer
+	RequirePushedAuthorizationRequests bool
+	// NoBrowser prints the authorization URL and reads the code from stdin
+	// instead of opening a browser and listening for the callback
+	NoBrowser bool
+	// Endpoints are used instead of discovering the endpoints from the issuer
+	// when they are set
+	Endpoints *OIDCWellKnownEndpoints
+	// MetadataCacheDir is the directory the issuer's discovery and JWKS
+	// documents are cached in. Nothing is cached when it is empty.
+	MetadataCacheDir string
+	// RefreshMetadata ignores the cached documents and gets them again
+	RefreshMetadata bool
+	// NoRetry sends every request to the issuer only once
+	NoRetry bool
+	// RetryTimeout caps the total time spent retrying a request.
+	// DefaultRetryTimeout is used when it is zero.
+	RetryTimeout time.Duration
+	// ClockSkew is how far the local clock is allowed to be behind the
+	// issuer's clock when verifying ID tokens
+	ClockSkew time.Duration
+	// DetectClockSkew verifies ID tokens against the issuer's clock as seen
+	// in the Date header of the token response
+	DetectClockSkew bool
+}
+
+// getWellKnownEndpointsForIssuer returns the manually configured endpoints
+// when they are set and otherwise discovers them from the issuer
+func getWellKnownEndpointsForIssuer(issuerData Issuer, options ClientOptions, transport HTTPAuthTransport) (*OIDCWellKnownEndpoints, error) {
+	if options.Endpoints == nil {
+		return GetOIDCWellKnownEndpointsUsingTransport(issuerData.IssuerEndpoint, transport)
+	}
+
+	endpoints := *options.Endpoints
+	if endpoints.Issuer == "" {
+		endpoints.Issuer = issuerData.IssuerEndpoint
+	}
+
+	if err := endpoints.Validate(issuerData.IssuerEndpoint); err != nil {
+		return nil, errors.Wrap(err, "invalid manually configured endpoints")
+	}
+
+	return &endpoints, nil
+}
+
+// newMetadataTransport builds the transport used to get the issuer's metadata
+// and signing keys. The documents are cached on disk when a cache directory is
+// configured.
+func newMetadataTransport(issuerData Issuer, options ClientOptions) HTTPAuthTransport {
+	transport := newRetryingTransport(&http.Client{}, options)
+	if options.MetadataCacheDir == "" {
+		return transport
+	}
+
+	return NewMetadataCacheTransport(options.MetadataCacheDir, issuerData.IssuerEndpoint, transport, options.RefreshMetadata)
+}
+
+// newRetryingTransport wraps <transport> in a RetryTransport unless retrying
+// is turned off
+func newRetryingTransport(transport HTTPAuthTransport, options ClientOptions) HTTPAuthTransport {
+	if options.NoRetry {
+		return transport
+	}
+
+	return NewRetryTransport(transport, options.RetryTimeout)
+}
+
+// newDefaultTokenRetriever gets the well known endpoints for the issuer and
+// builds a TokenRetriever that authenticates the client as configured by
+// <options>
+func newDefaultTokenRetriever(issuerData Issuer, options ClientOptions) (*TokenRetriever, *OIDCWellKnownEndpoints, error) {
+	metadataTransport := newMetadataTransport(issuerData, options)
+	wellKnownEndpoints, err := getWellKnownEndpointsForIssuer(issuerData, options, metadataTransport)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	method := options.ClientAuthMethod
+	if method == "" {
+		method, err = SelectClientAuthMethod(
+			wellKnownEndpoints.TokenEndpointAuthMethodsSupported,
+			options.ClientSecret != nil,
+			options.ClientKey != nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	clientAuthenticator, err := NewClientAuthenticator(method, issuerData.ClientID, options.ClientSecret, options.ClientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpClient := &http.Client{}
+	tokenEndpoints := *wellKnownEndpoints
+	if options.ClientCertificate != nil {
+		httpClient = newMTLSHTTPClient(*options.ClientCertificate)
+		tokenEndpoints = tokenEndpoints.WithMTLSEndpointAliases()
+	}
+
+	tokenRetriever := NewTokenRetriever(tokenEndpoints, newRetryingTransport(httpClient, options), clientAuthenticator)
+	if options.DPoPKey != nil {
+		tokenRetriever.dpopProver = NewDPoPProver(options.DPoPKey)
+	}
+
+	if wellKnownEndpoints.JWKSURI != "" {
+		issuer := wellKnownEndpoints.Issuer
+		if issuer == "" {
+			issuer = issuerData.IssuerEndpoint
+		}
+
+		tokenRetriever.idTokenVerifier = NewIDTokenVerifier(
+			issuer,
+			issuerData.ClientID,
+			NewRemoteKeySet(wellKnownEndpoints.JWKSURI, metadataTransport))
+		tokenRetriever.idTokenVerifier.clockSkew = options.ClockSkew
+		tokenRetriever.idTokenVerifier.detectClockSkew = options.DetectClockSkew
+	}
+
+	return tokenRetriever, wellKnownEndpoints, nil
+}
+
 // NewAccessTokenProvider allows for the easy setup AccessTokenProvider
 func NewAccessTokenProvider(
 	allowRefresh bool,
@@ -59,27 +221,54 @@ func NewAccessTokenProvider(
 	}
 }
 
+// NewDeviceAccessTokenProvider allows for the easy setup of an
+// AccessTokenProvider that authenticates using the device authorization grant
+func NewDeviceAccessTokenProvider(
+	allowRefresh bool,
+	issuerData Issuer,
+	deviceProvider DeviceAuthorizationProvider,
+	exchanger AuthorizationTokenExchanger) *TokenProvider {
+	return &TokenProvider{
+		allowRefresh:   allowRefresh,
+		issuerData:     issuerData,
+		deviceProvider: deviceProvider,
+		exchanger:      exchanger,
+	}
+}
+
 // NewDefaultAccessTokenProvider provides an easy way to build up a default
 // token provider with all the correct configuration. If refresh tokens should
 // be allowed pass in true for <allowRefresh>
-func NewDefaultAccessTokenProvider(issuerData Issuer, allowRefresh bool, port uint16) (*TokenProvider, error) {
-	wellKnownEndpoints, err := GetOIDCWellKnownEndpointsFromIssuerURL(issuerData.IssuerEndpoint)
+func NewDefaultAccessTokenProvider(issuerData Issuer, allowRefresh bool, port uint16, options ClientOptions) (*TokenProvider, error) {
+	tokenRetriever, wellKnownEndpoints, err := newDefaultTokenRetriever(issuerData, options)
 	if err != nil {
 		return nil, err
 	}
 
+	if wellKnownEndpoints.AuthorizationEndpoint == "" {
+		return nil, errors.New("the issuer does not advertise an authorization_endpoint")
+	}
+
+	osInteractor := &os.DefaultInteractor{}
+	var listener AuthorizationCallbackListener = NewLocalCallbackListener(int(port))
+	var urlOpener OSInteractor = osInteractor
+	if options.NoBrowser || !osInteractor.HasDisplay() {
+		manualCodeEntry := NewManualCodeEntry(int(port), goos.Stdin, goos.Stderr)
+		listener = manualCodeEntry
+		urlOpener = manualCodeEntry
+	}
+
 	codeProvider := NewLocalCodeProvider(
 		issuerData,
 		*wellKnownEndpoints,
-		NewLocalCallbackListener(int(port)),
-		&os.DefaultInteractor{},
+		listener,
+		urlOpener,
 		DefaultStateGenerator,
+		DefaultNonceGenerator,
+		tokenRetriever,
+		options.RequirePushedAuthorizationRequests,
 	)
 
-	tokenRetriever := NewTokenRetriever(
-		*wellKnownEndpoints,
-		&http.Client{})
-
 	return NewAccessTokenProvider(
 		allowRefresh,
 		issuerData,
@@ -88,16 +277,39 @@ func NewDefaultAccessTokenProvider(issuerData Issuer, allowRefresh bool, port ui
 		DefaultChallengeGenerator), nil
 }
 
+// NewDefaultDeviceAccessTokenProvider provides an easy way to build up a
+// default token provider that uses the device authorization grant. If refresh
+// tokens should be allowed pass in true for <allowRefresh>
+func NewDefaultDeviceAccessTokenProvider(issuerData Issuer, allowRefresh bool, options ClientOptions) (*TokenProvider, error) {
+	tokenRetriever, wellKnownEndpoints, err := newDefaultTokenRetriever(issuerData, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if wellKnownEndpoints.DeviceAuthorizationEndpoint == "" {
+		return nil, errors.New("the issuer does not advertise a device_authorization_endpoint")
+	}
+
+	return NewDeviceAccessTokenProvider(
+		allowRefresh,
+		issuerData,
+		NewDeviceCodeProvider(issuerData, tokenRetriever, goos.Stderr),
+		tokenRetriever), nil
+}
+
 // Authenticate is used to retrieve a TokenResult when the user has not yet
 // authenticated
 func (p *TokenProvider) Authenticate() (*TokenResult, error) {
-	challenge := p.challenger()
-
 	var additionalScopes []string
 	if p.allowRefresh {
 		additionalScopes = append(additionalScopes, "offline_access")
 	}
 
+	if p.deviceProvider != nil {
+		return p.deviceProvider.GetTokens(additionalScopes...)
+	}
+
+	challenge := p.challenger()
 	codeResult, err := p.codeProvider.GetCode(challenge, additionalScopes...)
 	if err != nil {
 		return nil, err
@@ -115,14 +327,38 @@ func (p *TokenProvider) Authenticate() (*TokenResult, error) {
 		return nil, errors.Wrap(err, "could not exchange code")
 	}
 
+	if err := verifyNonce(tokenResult.IDToken, codeResult.Nonce); err != nil {
+		return nil, err
+	}
+
 	return tokenResult, nil
 }
 
+// verifyNonce checks that the ID token was issued for the authorization
+// request that sent <nonce>. The signature of the ID token is verified by the
+// exchanger.
+func verifyNonce(idToken, nonce string) error {
+	if idToken == "" || nonce == "" {
+		return nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := (&jwt.Parser{}).ParseUnverified(idToken, claims); err != nil {
+		return errors.Wrap(err, "could not parse id token")
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return errors.New("id token nonce does not match the nonce sent in the authorization request")
+	}
+
+	return nil
+}
+
 // FromRefreshToken is used to retrieve a TokenResult when the user has already
 // authenticated but their Access Token has expired
 func (p *TokenProvider) FromRefreshToken(refreshToken string) (*TokenResult, error) {
 	if !p.allowRefresh {
-		return nil, errors.New("cannot use refresh token as it was not allowed to be used by the client")
+		return nil, refreshNotSupportedError("cannot use refresh token as it was not allowed to be used by the client")
 	}
 
 	exchangeRequest := RefreshTokenExchangeRequest{
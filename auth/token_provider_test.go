@@ -3,6 +3,7 @@ package auth
 import (
 	"errors"
 
+	jwt "github.com/dgrijalva/jwt-go"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -40,6 +41,17 @@ func (te *MockTokenExchanger) ExchangeRefreshToken(req RefreshTokenExchangeReque
 	return te.ReturnsTokens, te.ReturnsError
 }
 
+type mockDeviceProvider struct {
+	CalledWithAdditionalScopes []string
+	ReturnsTokens              *TokenResult
+	ReturnsError               error
+}
+
+func (dp *mockDeviceProvider) GetTokens(additionalScopes ...string) (*TokenResult, error) {
+	dp.CalledWithAdditionalScopes = additionalScopes
+	return dp.ReturnsTokens, dp.ReturnsError
+}
+
 var _ = Describe("AccessTokenProvider", func() {
 	issuer := Issuer{
 		IssuerEndpoint: "http://issuer",
@@ -162,6 +174,68 @@ var _ = Describe("AccessTokenProvider", func() {
 
 			Expect(err.Error()).To(Equal("could not exchange code: someerror"))
 		})
+
+		Describe("nonce", func() {
+			idTokenWithNonce := func(nonce string) string {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"nonce": nonce}).SignedString([]byte("secret"))
+				Expect(err).NotTo(HaveOccurred())
+				return token
+			}
+
+			BeforeEach(func() {
+				mockCodeProvider.AuthCodeResult.Nonce = "nonce1234"
+			})
+
+			It("accepts an ID token with the nonce of the authorization request", func() {
+				mockTokenExchanger.ReturnsTokens.IDToken = idTokenWithNonce("nonce1234")
+				provider := NewAccessTokenProvider(false, issuer, mockCodeProvider, mockTokenExchanger, mockChallenger)
+
+				tokens, err := provider.Authenticate()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tokens).To(Equal(mockTokenExchanger.ReturnsTokens))
+			})
+
+			It("rejects an ID token with another nonce", func() {
+				mockTokenExchanger.ReturnsTokens.IDToken = idTokenWithNonce("other")
+				provider := NewAccessTokenProvider(false, issuer, mockCodeProvider, mockTokenExchanger, mockChallenger)
+
+				tokens, err := provider.Authenticate()
+
+				Expect(tokens).To(BeNil())
+				Expect(err.Error()).To(Equal("id token nonce does not match the nonce sent in the authorization request"))
+			})
+
+			It("rejects an ID token without a nonce", func() {
+				token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{}).SignedString([]byte("secret"))
+				Expect(err).NotTo(HaveOccurred())
+				mockTokenExchanger.ReturnsTokens.IDToken = token
+				provider := NewAccessTokenProvider(false, issuer, mockCodeProvider, mockTokenExchanger, mockChallenger)
+
+				_, err = provider.Authenticate()
+
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		It("uses the device provider when one is set", func() {
+			mockDevice := &mockDeviceProvider{
+				ReturnsTokens: &TokenResult{AccessToken: "deviceToken"},
+			}
+			provider := NewDeviceAccessTokenProvider(
+				true,
+				issuer,
+				mockDevice,
+				mockTokenExchanger,
+			)
+
+			tokens, err := provider.Authenticate()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokens).To(Equal(mockDevice.ReturnsTokens))
+			Expect(mockDevice.CalledWithAdditionalScopes).To(Equal([]string{"offline_access"}))
+			Expect(mockTokenExchanger.CalledWithRequest).To(BeNil())
+		})
 	})
 
 	Describe("ExchangeRefreshToken", func() {
@@ -214,4 +288,46 @@ var _ = Describe("AccessTokenProvider", func() {
 			Expect(err.Error()).To(Equal("cannot use refresh token as it was not allowed to be used by the client"))
 		})
 	})
+
+	Describe("getWellKnownEndpointsForIssuer", func() {
+		It("uses the manually configured endpoints without discovery", func() {
+			transport := &mockTransport{}
+			endpoints, err := getWellKnownEndpointsForIssuer(Issuer{IssuerEndpoint: "https://issuer"}, ClientOptions{
+				Endpoints: &OIDCWellKnownEndpoints{
+					AuthorizationEndpoint: "https://issuer/authorize",
+					TokenEndpoint:         "https://issuer/token",
+				},
+			}, transport)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(transport.Requests).To(BeEmpty())
+			Expect(endpoints).To(Equal(&OIDCWellKnownEndpoints{
+				Issuer:                "https://issuer",
+				AuthorizationEndpoint: "https://issuer/authorize",
+				TokenEndpoint:         "https://issuer/token",
+			}))
+		})
+
+		It("validates the manually configured endpoints", func() {
+			_, err := getWellKnownEndpointsForIssuer(Issuer{IssuerEndpoint: "https://issuer"}, ClientOptions{
+				Endpoints: &OIDCWellKnownEndpoints{TokenEndpoint: "http://issuer/token"},
+			}, &mockTransport{})
+
+			Expect(err.Error()).To(Equal(`invalid manually configured endpoints: the token_endpoint is invalid: "http://issuer/token" does not use https`))
+		})
+
+		It("builds a token retriever that uses the manually configured endpoints", func() {
+			tokenRetriever, endpoints, err := newDefaultTokenRetriever(Issuer{IssuerEndpoint: "https://issuer"}, ClientOptions{
+				Endpoints: &OIDCWellKnownEndpoints{
+					TokenEndpoint: "https://issuer/token",
+					JWKSURI:       "https://issuer/jwks",
+				},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(endpoints.TokenEndpoint).To(Equal("https://issuer/token"))
+			Expect(tokenRetriever.oidcWellKnownEndpoints.TokenEndpoint).To(Equal("https://issuer/token"))
+			Expect(tokenRetriever.idTokenVerifier).NotTo(BeNil())
+		})
+	})
 })
@@ -54,3 +54,12 @@ type State func() string
 func DefaultStateGenerator() string {
 	return generateRandomString(32)
 }
+
+// Nonce is used to generate a new nonce string that binds the ID token to
+// the authorization request
+type Nonce func() string
+
+// DefaultNonceGenerator generates a default Nonce
+func DefaultNonceGenerator() string {
+	return generateRandomString(32)
+}
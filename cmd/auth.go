@@ -4,14 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/99designs/keyring"
 	"github.com/auth0/k8s-pixy-auth/auth"
+	"github.com/auth0/k8s-pixy-auth/initialization"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh/terminal"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
 )
 
 func init() {
@@ -19,8 +20,8 @@ func init() {
 }
 
 type tokenProvider interface {
-	GetAccessToken() (string, error)
-	GetIDToken() (string, error)
+	GetAccessTokenWithExpiry() (string, time.Time, error)
+	GetIDTokenWithExpiry() (string, time.Time, error)
 }
 
 var authCmd = &cobra.Command{
@@ -33,31 +34,38 @@ var authCmd = &cobra.Command{
 			return errors.Wrap(err, "could not set up keyring")
 		}
 
-		provider, err := newCachingTokenProviderUsingKeyring(issuerEndpoint, clientID, audience, withRefreshToken, port, k)
+		info, err := readExecInfo()
+		if err != nil {
+			return err
+		}
+
+		issuer := info.Cluster.Issuer(auth.Issuer{
+			IssuerEndpoint: issuerEndpoint,
+			ClientID:       clientID,
+			Audience:       audience,
+		})
+		if issuer.IssuerEndpoint == "" || issuer.ClientID == "" {
+			return errors.New("--issuer-endpoint and --client-id are required unless kubectl provides them through the cluster info")
+		}
+
+		provider, err := newCachingTokenProviderUsingKeyring(issuer.IssuerEndpoint, issuer.ClientID, issuer.Audience, hubAudience, withRefreshToken, port, flow, info.Interactive, k)
 		if err != nil {
 			return errors.Wrap(err, "could not build caching token provider")
 		}
 
 		var token string
+		var expiry time.Time
 		if useIDToken {
-			token, err = provider.GetIDToken()
+			token, expiry, err = provider.GetIDTokenWithExpiry()
 		} else {
-			token, err = provider.GetAccessToken()
+			token, expiry, err = provider.GetAccessTokenWithExpiry()
 		}
 
 		if err != nil {
 			return errors.Wrap(err, "could not get access token for auth")
 		}
 
-		creds := v1beta1.ExecCredential{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "ExecCredential",
-				APIVersion: "client.authentication.k8s.io/v1beta1",
-			},
-			Status: &v1beta1.ExecCredentialStatus{
-				Token: token,
-			},
-		}
+		creds := newExecCredential(info.APIVersion, token, expiry)
 
 		jCreds, _ := json.Marshal(creds)
 		fmt.Println(string(jCreds))
@@ -65,19 +73,234 @@ var authCmd = &cobra.Command{
 	},
 }
 
-func newCachingTokenProviderUsingKeyring(issuer, clientID, audience string, withRefreshToken bool, port uint16, k keyring.Keyring) (tokenProvider, error) {
-	atProvider, err := auth.NewDefaultAccessTokenProvider(auth.Issuer{
+// tokenCache mirrors the interface auth.CachingTokenProvider uses to cache
+// tokens
+type tokenCache interface {
+	GetTokens() (*auth.TokenResult, error)
+	CacheTokens(*auth.TokenResult) error
+	ClearTokens() error
+}
+
+func newCachingTokenProviderUsingKeyring(issuer, clientID, audience, hubAudience string, withRefreshToken bool, port uint16, flow string, interactive bool, k keyring.Keyring) (tokenProvider, error) {
+	issuerData := auth.Issuer{
 		IssuerEndpoint: issuer,
 		ClientID:       clientID,
 		Audience:       audience,
-	}, withRefreshToken, port)
+	}
+
+	execOptions := execOptionsFromFlags()
+	options, err := newClientOptions(clientID, execOptions, k)
+	if err != nil {
+		return nil, err
+	}
+
+	cachingOptions, err := newCachingOptions(execOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if hubAudience == "" {
+		itProvider, err := newIssuerTokenProvider(issuerData, withRefreshToken, port, flow, interactive, options)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not build access token provider")
+		}
+
+		return auth.NewCachingTokenProviderWithOptions(
+			newKeyringTokenCache(clientID, audience, k, options),
+			itProvider,
+			cachingOptions), nil
+	}
+
+	hubIssuerData := issuerData
+	hubIssuerData.Audience = hubAudience
+	hubProvider, err := newIssuerTokenProvider(hubIssuerData, withRefreshToken, port, flow, interactive, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build hub access token provider")
+	}
+
+	requestedTokenType := auth.TokenTypeAccessToken
+	if useIDToken {
+		requestedTokenType = auth.TokenTypeIDToken
+	}
+
+	hubCachingProvider := auth.NewCachingTokenProviderWithOptions(
+		newKeyringTokenCache(clientID, hubAudience, k, options),
+		hubProvider,
+		cachingOptions)
+
+	exchangeProvider := auth.NewLazyTokenProvider(func() (auth.IssuerTokenProvider, error) {
+		exchangeProvider, err := auth.NewDefaultTokenExchangeProvider(
+			issuerData,
+			requestedTokenType,
+			hubCachingProvider,
+			options)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not build token exchange provider")
+		}
+
+		return exchangeProvider, nil
+	})
+
+	return auth.NewCachingTokenProviderWithOptions(
+		newKeyringTokenCache(clientID, audience, k, options),
+		exchangeProvider,
+		cachingOptions), nil
+}
+
+// newKeyringTokenCache builds the keyring backed token cache for the client
+// and audience. Tokens are bound to the client certificate when one is used.
+func newKeyringTokenCache(clientID, audience string, k keyring.Keyring, options auth.ClientOptions) tokenCache {
+	cache := auth.NewKeyringCachingProvider(clientID, audience, k)
+	if options.ClientCertificate == nil {
+		return cache
+	}
+
+	return auth.NewCertificateBoundCachingProvider(cache, auth.CertificateThumbprint(*options.ClientCertificate))
+}
+
+// newIssuerTokenProvider builds the IssuerTokenProvider for the passed in
+// flow. The flow is checked right away but the provider itself is built
+// lazily so that discovery only happens when the cached tokens cannot be used.
+// The browser and device flows fail instead of asking the user to log in when
+// <interactive> is not set.
+func newIssuerTokenProvider(issuerData auth.Issuer, withRefreshToken bool, port uint16, flow string, interactive bool, options auth.ClientOptions) (auth.IssuerTokenProvider, error) {
+	var build func() (auth.IssuerTokenProvider, error)
+
+	switch flow {
+	case flowBrowser:
+		build = func() (auth.IssuerTokenProvider, error) {
+			return auth.NewDefaultAccessTokenProvider(issuerData, withRefreshToken, port, options)
+		}
+	case flowDevice:
+		build = func() (auth.IssuerTokenProvider, error) {
+			return auth.NewDefaultDeviceAccessTokenProvider(issuerData, withRefreshToken, options)
+		}
+	case flowClientCredentials:
+		build = func() (auth.IssuerTokenProvider, error) {
+			return auth.NewDefaultClientCredentialsTokenProvider(issuerData, options)
+		}
+	case flowJWTBearer:
+		if assertionFile == "" {
+			return nil, errors.New("--assertion-file is required for the jwt-bearer flow")
+		}
+		build = func() (auth.IssuerTokenProvider, error) {
+			return auth.NewDefaultJWTBearerTokenProvider(issuerData, assertionFile, options)
+		}
+	default:
+		return nil, fmt.Errorf("unknown flow %q", flow)
+	}
+
+	provider := auth.NewLazyTokenProvider(build)
+	if !interactive && (flow == flowBrowser || flow == flowDevice) {
+		return auth.NewNonInteractiveTokenProvider(provider), nil
+	}
+
+	return provider, nil
+}
+
+// newCachingOptions builds the auth.CachingOptions from the refresh failure
+// policy and expiry options. Why the user has to log in again is reported on
+// stderr.
+func newCachingOptions(execOptions initialization.ExecOptions) (auth.CachingOptions, error) {
+	policy, err := auth.ParseRefreshFailurePolicy(execOptions.ReauthenticateOn)
+	if err != nil {
+		return auth.CachingOptions{}, err
+	}
+
+	return auth.CachingOptions{
+		RefreshFailurePolicy: policy,
+		Output:               os.Stderr,
+		RefreshAhead:         execOptions.RefreshAhead,
+		ClockSkew:            execOptions.ClockSkew,
+		DetectClockSkew:      execOptions.DetectClockSkew,
+	}, nil
+}
+
+// newClientOptions builds the auth.ClientOptions from the client
+// authentication options. The DPoP key is kept in the keyring.
+func newClientOptions(clientID string, execOptions initialization.ExecOptions, k keyring.Keyring) (auth.ClientOptions, error) {
+	clientSecret, err := newSecretReader("client-secret", execOptions.ClientSecretEnv, execOptions.ClientSecretFile)
+	if err != nil {
+		return auth.ClientOptions{}, err
+	}
+
+	clientKey, err := newSecretReader("client-key", execOptions.ClientKeyEnv, execOptions.ClientKeyFile)
+	if err != nil {
+		return auth.ClientOptions{}, err
+	}
+
+	options := auth.ClientOptions{
+		ClientAuthMethod:                   execOptions.ClientAuthMethod,
+		ClientSecret:                       clientSecret,
+		ClientKey:                          clientKey,
+		RequirePushedAuthorizationRequests: execOptions.RequirePAR,
+		NoBrowser:                          execOptions.NoBrowser,
+		MetadataCacheDir:                   metadataCacheDir(),
+		RefreshMetadata:                    refreshMetadata,
+		NoRetry:                            execOptions.NoRetry,
+		RetryTimeout:                       execOptions.RetryTimeout,
+		ClockSkew:                          execOptions.ClockSkew,
+		DetectClockSkew:                    execOptions.DetectClockSkew,
+	}
+
+	if execOptions.ClientCertFile != "" || execOptions.ClientCertKeyFile != "" {
+		if execOptions.ClientCertFile == "" || execOptions.ClientCertKeyFile == "" {
+			return auth.ClientOptions{}, errors.New("--client-cert-file and --client-cert-key-file must be set together")
+		}
+
+		options.ClientCertificate, err = auth.LoadClientCertificate(execOptions.ClientCertFile, execOptions.ClientCertKeyFile)
+		if err != nil {
+			return auth.ClientOptions{}, err
+		}
+	}
+
+	if execOptions.AuthorizationEndpoint != "" || execOptions.TokenEndpoint != "" || execOptions.JWKSURI != "" || execOptions.RevocationEndpoint != "" {
+		if execOptions.TokenEndpoint == "" {
+			return auth.ClientOptions{}, errors.New("--token-endpoint is required when endpoints are set manually")
+		}
+
+		options.Endpoints = &auth.OIDCWellKnownEndpoints{
+			AuthorizationEndpoint: execOptions.AuthorizationEndpoint,
+			TokenEndpoint:         execOptions.TokenEndpoint,
+			JWKSURI:               execOptions.JWKSURI,
+			RevocationEndpoint:    execOptions.RevocationEndpoint,
+		}
+	}
+
+	if execOptions.DPoP {
+		options.DPoPKey, err = auth.LoadOrCreateDPoPKey(k, clientID)
+		if err != nil {
+			return auth.ClientOptions{}, err
+		}
+	}
+
+	return options, nil
+}
+
+// newSecretReader builds a SecretReader from the --<name>-env and
+// --<name>-file flags. A nil SecretReader is returned when neither is set.
+func newSecretReader(name, env, file string) (auth.SecretReader, error) {
+	switch {
+	case env != "" && file != "":
+		return nil, fmt.Errorf("only one of --%s-env or --%s-file can be set", name, name)
+	case env != "":
+		return auth.NewEnvSecretReader(env), nil
+	case file != "":
+		return auth.NewFileSecretReader(file), nil
+	}
+
+	return nil, nil
+}
+
+// metadataCacheDir is the directory the issuer metadata is cached in. Nothing
+// is cached when the home directory cannot be found.
+func metadataCacheDir() string {
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not build access token provider")
+		return ""
 	}
 
-	return auth.NewCachingTokenProvider(
-		auth.NewKeyringCachingProvider(clientID, audience, k),
-		atProvider), nil
+	return filepath.Join(home, ".k8s-pixy-auth", "metadata-cache")
 }
 
 func getK8sKeyringSetup() (keyring.Keyring, error) {
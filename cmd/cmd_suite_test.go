@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/reporters"
+	. "github.com/onsi/gomega"
+)
+
+func TestCmd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	junitReporter := reporters.NewJUnitReporter("../test-results/junit/cmd.xml")
+	RunSpecsWithDefaultAndCustomReporters(t, "Auth0KubectlAuth Cmd Suite", []Reporter{junitReporter})
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/auth0/k8s-pixy-auth/initialization"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	"k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+)
+
+const (
+	execInfoEnv       = "KUBERNETES_EXEC_INFO"
+	apiVersionV1      = "client.authentication.k8s.io/v1"
+	apiVersionV1beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// execInfo is what kubectl tells the plugin through KUBERNETES_EXEC_INFO
+type execInfo struct {
+	APIVersion  string
+	Interactive bool
+	// Cluster is the issuer kept in the cluster extension when kubectl
+	// provides the cluster info
+	Cluster initialization.ClusterIssuerConfig
+}
+
+// readExecInfo reads the ExecCredential kubectl passes in
+// KUBERNETES_EXEC_INFO
+func readExecInfo() (execInfo, error) {
+	return parseExecInfo(os.Getenv(execInfoEnv))
+}
+
+// parseExecInfo parses the ExecCredential kubectl passed in. Older kubectl
+// versions do not pass one, in which case v1beta1 and an interactive terminal
+// are assumed. They also leave out spec.interactive from v1beta1, which is
+// treated as interactive as well.
+func parseExecInfo(raw string) (execInfo, error) {
+	if raw == "" {
+		return execInfo{APIVersion: apiVersionV1beta1, Interactive: true}, nil
+	}
+
+	var cred struct {
+		metav1.TypeMeta `json:",inline"`
+		Spec            struct {
+			Interactive *bool `json:"interactive"`
+			Cluster     *struct {
+				Config json.RawMessage `json:"config"`
+			} `json:"cluster"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal([]byte(raw), &cred); err != nil {
+		return execInfo{}, fmt.Errorf("could not parse %s: %s", execInfoEnv, err)
+	}
+
+	switch cred.APIVersion {
+	case apiVersionV1, apiVersionV1beta1:
+	default:
+		return execInfo{}, fmt.Errorf("unsupported exec credential API version %q in %s", cred.APIVersion, execInfoEnv)
+	}
+
+	info := execInfo{APIVersion: cred.APIVersion, Interactive: true}
+	if cred.Spec.Interactive != nil {
+		info.Interactive = *cred.Spec.Interactive
+	}
+	if cred.Spec.Cluster != nil && len(cred.Spec.Cluster.Config) > 0 && string(cred.Spec.Cluster.Config) != "null" {
+		issuerConfig, err := initialization.ParseClusterIssuerConfig(cred.Spec.Cluster.Config)
+		if err != nil {
+			return execInfo{}, err
+		}
+		info.Cluster = issuerConfig
+	}
+
+	return info, nil
+}
+
+// newExecCredential builds the ExecCredential for <apiVersion> that hands the
+// token to kubectl. The expiry is left out when it is not known.
+func newExecCredential(apiVersion, token string, expiry time.Time) interface{} {
+	var expirationTimestamp *metav1.Time
+	if !expiry.IsZero() {
+		// lets client-go reuse the credential until it expires
+		expirationTimestamp = &metav1.Time{Time: expiry}
+	}
+
+	typeMeta := metav1.TypeMeta{
+		Kind:       "ExecCredential",
+		APIVersion: apiVersion,
+	}
+
+	if apiVersion == apiVersionV1 {
+		return v1.ExecCredential{
+			TypeMeta: typeMeta,
+			Status: &v1.ExecCredentialStatus{
+				Token:               token,
+				ExpirationTimestamp: expirationTimestamp,
+			},
+		}
+	}
+
+	return v1beta1.ExecCredential{
+		TypeMeta: typeMeta,
+		Status: &v1beta1.ExecCredentialStatus{
+			Token:               token,
+			ExpirationTimestamp: expirationTimestamp,
+		},
+	}
+}
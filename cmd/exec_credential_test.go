@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/auth0/k8s-pixy-auth/auth"
+	"github.com/auth0/k8s-pixy-auth/initialization"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseExecInfo", func() {
+	It("assumes v1beta1 and an interactive terminal when kubectl passes nothing", func() {
+		info, err := parseExecInfo("")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info).To(Equal(execInfo{APIVersion: apiVersionV1beta1, Interactive: true}))
+	})
+
+	It("treats a missing spec.interactive as interactive", func() {
+		info, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","spec":{}}`)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.APIVersion).To(Equal(apiVersionV1beta1))
+		Expect(info.Interactive).To(BeTrue())
+	})
+
+	It("uses spec.interactive when it is set", func() {
+		info, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":false}}`)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.APIVersion).To(Equal(apiVersionV1))
+		Expect(info.Interactive).To(BeFalse())
+	})
+
+	It("rejects unsupported API versions", func() {
+		_, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1alpha1","kind":"ExecCredential","spec":{}}`)
+
+		Expect(err).To(MatchError(`unsupported exec credential API version "client.authentication.k8s.io/v1alpha1" in KUBERNETES_EXEC_INFO`))
+	})
+
+	It("reads the issuer from the cluster config and lets it override the flags", func() {
+		info, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":true,"cluster":{"server":"https://cluster","config":{"issuerEndpoint":"https://cluster-issuer","audience":"cluster"}}}}`)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Cluster).To(Equal(initialization.ClusterIssuerConfig{IssuerEndpoint: "https://cluster-issuer", Audience: "cluster"}))
+		Expect(info.Cluster.Issuer(auth.Issuer{IssuerEndpoint: "https://issuer", ClientID: "clientID", Audience: "audience"})).To(Equal(auth.Issuer{
+			IssuerEndpoint: "https://cluster-issuer",
+			ClientID:       "clientID",
+			Audience:       "cluster",
+		}))
+	})
+
+	It("ignores a cluster without a config", func() {
+		info, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":true,"cluster":{"server":"https://cluster","config":null}}}`)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Cluster).To(Equal(initialization.ClusterIssuerConfig{}))
+	})
+
+	It("errors when the cluster config is malformed", func() {
+		_, err := parseExecInfo(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":true,"cluster":{"server":"https://cluster","config":"issuer"}}}`)
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("could not parse the client.authentication.k8s.io/exec cluster extension: "))
+	})
+
+	It("errors when KUBERNETES_EXEC_INFO is not json", func() {
+		_, err := parseExecInfo("{")
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("could not parse KUBERNETES_EXEC_INFO: "))
+	})
+})
+
+var _ = Describe("newExecCredential", func() {
+	toJSON := func(credential interface{}) string {
+		b, err := json.Marshal(credential)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	It("builds a v1 ExecCredential with the expiry", func() {
+		expiry := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		credential := newExecCredential(apiVersionV1, "token", expiry)
+
+		Expect(toJSON(credential)).To(MatchJSON(`{
+			"kind": "ExecCredential",
+			"apiVersion": "client.authentication.k8s.io/v1",
+			"spec": {"interactive": false},
+			"status": {"token": "token", "expirationTimestamp": "2020-01-01T12:00:00Z"}
+		}`))
+	})
+
+	It("builds a v1beta1 ExecCredential with the expiry", func() {
+		expiry := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		credential := newExecCredential(apiVersionV1beta1, "token", expiry)
+
+		Expect(toJSON(credential)).To(MatchJSON(`{
+			"kind": "ExecCredential",
+			"apiVersion": "client.authentication.k8s.io/v1beta1",
+			"spec": {"interactive": false},
+			"status": {"token": "token", "expirationTimestamp": "2020-01-01T12:00:00Z"}
+		}`))
+	})
+
+	It("leaves the expiry out when it is not known", func() {
+		credential := newExecCredential(apiVersionV1beta1, "token", time.Time{})
+
+		Expect(toJSON(credential)).To(MatchJSON(`{
+			"kind": "ExecCredential",
+			"apiVersion": "client.authentication.k8s.io/v1beta1",
+			"spec": {"interactive": false},
+			"status": {"token": "token"}
+		}`))
+	})
+})
@@ -9,10 +9,14 @@ import (
 )
 
 var contextName string
+var execAPIVersion string
+var provideClusterInfo bool
 
 func init() {
 	initCmd.Flags().StringVarP(&contextName, "context-name", "n", "", "the kube config context name to init for")
 	initCmd.MarkFlagRequired("context-name")
+	initCmd.Flags().BoolVar(&provideClusterInfo, "provide-cluster-info", false, "write the issuer endpoint, client id and audience to the context's cluster extension and have kubectl provide them instead of adding them to the exec args")
+	initCmd.Flags().StringVar(&execAPIVersion, "exec-api-version", "v1beta1", "the client.authentication.k8s.io version of the kube config exec entry: v1beta1 or v1. v1 needs kubectl 1.22 or newer")
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -34,9 +38,61 @@ var initCmd = &cobra.Command{
 			IssuerEndpoint: issuerEndpoint,
 			ClientID:       clientID,
 			Audience:       audience,
-		}, useIDToken, withRefreshToken, port)
+		}, execOptionsFromFlags())
 		if err != nil {
 			panic(err)
 		}
+
+		if tokenEndpoint == "" {
+			reportDiscovery(issuerEndpoint)
+		}
 	},
 }
+
+// reportDiscovery tells which well known document of the issuer is used so
+// that problems with discovery show up when setting up instead of when
+// authenticating
+func reportDiscovery(issuerURL string) {
+	endpoints, err := auth.GetOIDCWellKnownEndpointsFromIssuerURL(issuerURL)
+	if err != nil {
+		fmt.Printf("Could not get the issuer metadata: %s\n", err)
+		return
+	}
+
+	fmt.Printf("Using the issuer metadata from %s\n", endpoints.DiscoveryURL)
+}
+
+// execOptionsFromFlags builds the initialization.ExecOptions from the flags
+func execOptionsFromFlags() initialization.ExecOptions {
+	return initialization.ExecOptions{
+		UseIDToken:        useIDToken,
+		WithRefreshToken:  withRefreshToken,
+		Port:              port,
+		Flow:              flow,
+		ClientSecretEnv:   clientSecretEnv,
+		ClientSecretFile:  clientSecretFile,
+		ClientKeyEnv:      clientKeyEnv,
+		ClientKeyFile:     clientKeyFile,
+		ClientAuthMethod:  clientAuthMethod,
+		ClientCertFile:    clientCertFile,
+		ClientCertKeyFile: clientCertKeyFile,
+		HubAudience:       hubAudience,
+		AssertionFile:     assertionFile,
+		DPoP:              useDPoP,
+		RequirePAR:        requirePAR,
+		NoBrowser:         noBrowser,
+
+		AuthorizationEndpoint: authorizationEndpoint,
+		TokenEndpoint:         tokenEndpoint,
+		JWKSURI:               jwksURI,
+		RevocationEndpoint:    revocationEndpoint,
+		ReauthenticateOn:      reauthenticateOn,
+		NoRetry:               noRetry,
+		RetryTimeout:          retryTimeout,
+		RefreshAhead:          refreshAhead,
+		ClockSkew:             clockSkew,
+		DetectClockSkew:       detectClockSkew,
+		APIVersion:            execAPIVersion,
+		ProvideClusterInfo:    provideClusterInfo,
+	}
+}
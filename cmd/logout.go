@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/99designs/keyring"
+	"github.com/auth0/k8s-pixy-auth/auth"
+	"github.com/auth0/k8s-pixy-auth/initialization"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var logoutAll bool
+var endSession bool
+
+func init() {
+	logoutCmd.Flags().StringVarP(&contextName, "context-name", "n", "", "the kube config context name to log out of")
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "log out of every kube config context that uses k8s-pixy-auth")
+	logoutCmd.Flags().BoolVar(&endSession, "end-session", false, "if the session at the issuer should be ended too")
+	rootCmd.AddCommand(logoutCmd)
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Revoke and remove the cached credentials",
+	Long:  "Revokes the cached refresh token at the issuer and removes the cached tokens from the keyring for the specified context or for every context.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (contextName == "") == !logoutAll {
+			return errors.New("exactly one of --context-name or --all must be set")
+		}
+
+		execConfigs, err := initialization.NewDefaultInitializer().GetExecConfigs(contextName)
+		if err != nil {
+			return err
+		}
+
+		k, err := getK8sKeyringSetup()
+		if err != nil {
+			return errors.Wrap(err, "could not set up keyring")
+		}
+
+		failed := false
+		for _, execConfig := range execConfigs {
+			if err := logout(execConfig, k); err != nil {
+				fmt.Fprintf(os.Stderr, "could not log out of %s: %s\n", execConfig.ContextName, err)
+				failed = true
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "Logged out of %s\n", execConfig.ContextName)
+		}
+
+		if failed {
+			return errors.New("could not log out of every context")
+		}
+
+		return nil
+	},
+}
+
+// logout logs out of the audience of the context and of its hub audience
+// when tokens are exchanged from one. The cached tokens are removed before
+// anything that can fail so that they never outlive a logout; only revoking
+// the refresh token and ending the session need the client to be set up.
+// Only the session of the audience that was logged in to is ended. The DPoP
+// key of the client is removed last so that none is created just to log out.
+func logout(execConfig initialization.ExecConfig, k keyring.Keyring) error {
+	audiences := []string{execConfig.Issuer.Audience}
+	if execConfig.Options.HubAudience != "" {
+		audiences = append(audiences, execConfig.Options.HubAudience)
+	}
+
+	removed := make([]*removedTokens, len(audiences))
+	for i, audience := range audiences {
+		tokens, err := removeTokens(auth.NewKeyringCachingProvider(execConfig.Issuer.ClientID, audience, k))
+		if err != nil {
+			return err
+		}
+		removed[i] = tokens
+	}
+
+	execOptions := execConfig.Options
+	execOptions.DPoP = false
+	options, err := newClientOptions(execConfig.Issuer.ClientID, execOptions, k)
+	if err != nil {
+		if endSession {
+			return errors.Wrap(err, "removed the cached tokens but could not end the session at the issuer")
+		}
+
+		for _, tokens := range removed {
+			if tokens.tokenResult != nil && tokens.tokenResult.RefreshToken != "" {
+				fmt.Fprintf(os.Stderr, "Removed the cached tokens of %s but could not revoke the refresh token: %s\n", execConfig.ContextName, err)
+				break
+			}
+		}
+
+		return removeDPoPKey(execConfig, k)
+	}
+
+	for i, audience := range audiences {
+		issuerData := execConfig.Issuer
+		issuerData.Audience = audience
+
+		logoutProvider, err := auth.NewDefaultLogoutProvider(issuerData, removed[i], options)
+		if err != nil {
+			return errors.Wrap(err, "could not build logout provider")
+		}
+
+		if err := logoutProvider.Logout(endSession && i == len(audiences)-1); err != nil {
+			return err
+		}
+	}
+
+	return removeDPoPKey(execConfig, k)
+}
+
+// removeDPoPKey removes the DPoP key of the client when the context uses DPoP
+func removeDPoPKey(execConfig initialization.ExecConfig, k keyring.Keyring) error {
+	if !execConfig.Options.DPoP {
+		return nil
+	}
+
+	return auth.RemoveDPoPKey(k, execConfig.Issuer.ClientID)
+}
+
+// removedTokens holds the tokens that were removed from the keyring so that
+// the LogoutProvider can still revoke the refresh token. It satisfies the
+// tokenCache interface.
+type removedTokens struct {
+	tokenResult *auth.TokenResult
+	err         error
+}
+
+// removeTokens reads the cached tokens and removes them from <cache>. An
+// error reading them is kept for the LogoutProvider to report.
+func removeTokens(cache tokenCache) (*removedTokens, error) {
+	tokenResult, getErr := cache.GetTokens()
+
+	if err := cache.ClearTokens(); err != nil {
+		return nil, errors.Wrap(err, "could not remove the cached tokens")
+	}
+
+	return &removedTokens{tokenResult: tokenResult, err: getErr}, nil
+}
+
+// GetTokens returns the tokens that were removed
+func (r *removedTokens) GetTokens() (*auth.TokenResult, error) {
+	return r.tokenResult, r.err
+}
+
+// CacheTokens always fails as nothing is cached while logging out
+func (r *removedTokens) CacheTokens(*auth.TokenResult) error {
+	return errors.New("tokens cannot be cached while logging out")
+}
+
+// ClearTokens does nothing as the tokens were removed already
+func (r *removedTokens) ClearTokens() error {
+	return nil
+}
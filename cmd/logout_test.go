@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/99designs/keyring"
+	"github.com/auth0/k8s-pixy-auth/auth"
+	"github.com/auth0/k8s-pixy-auth/initialization"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logout", func() {
+	var k *keyring.ArrayKeyring
+	var execConfig initialization.ExecConfig
+
+	cacheTokens := func(audience string) {
+		err := auth.NewKeyringCachingProvider("clientID", audience, k).CacheTokens(&auth.TokenResult{AccessToken: "at", RefreshToken: "rt"})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	cachedTokens := func(audience string) *auth.TokenResult {
+		tokenResult, err := auth.NewKeyringCachingProvider("clientID", audience, k).GetTokens()
+		Expect(err).NotTo(HaveOccurred())
+		return tokenResult
+	}
+
+	BeforeEach(func() {
+		k = keyring.NewArrayKeyring(nil)
+		execConfig = initialization.ExecConfig{
+			ContextName: "context",
+			Issuer:      auth.Issuer{IssuerEndpoint: "https://issuer", ClientID: "clientID", Audience: "audience"},
+			Options: initialization.ExecOptions{
+				HubAudience:    "hub",
+				ClientCertFile: "/moved/cert.pem",
+			},
+		}
+		cacheTokens("audience")
+		cacheTokens("hub")
+	})
+
+	AfterEach(func() {
+		endSession = false
+	})
+
+	It("removes the cached tokens when the client cannot be set up", func() {
+		err := logout(execConfig, k)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cachedTokens("audience")).To(BeNil())
+		Expect(cachedTokens("hub")).To(BeNil())
+	})
+
+	It("removes the cached tokens before failing to end the session", func() {
+		endSession = true
+
+		err := logout(execConfig, k)
+
+		Expect(err).To(MatchError("removed the cached tokens but could not end the session at the issuer: --client-cert-file and --client-cert-key-file must be set together"))
+		Expect(cachedTokens("audience")).To(BeNil())
+		Expect(cachedTokens("hub")).To(BeNil())
+	})
+})
+
+var _ = Describe("removeTokens", func() {
+	It("keeps the removed tokens for revoking them", func() {
+		k := keyring.NewArrayKeyring(nil)
+		cache := auth.NewKeyringCachingProvider("clientID", "audience", k)
+		Expect(cache.CacheTokens(&auth.TokenResult{RefreshToken: "rt"})).To(Succeed())
+
+		removed, err := removeTokens(cache)
+
+		Expect(err).NotTo(HaveOccurred())
+		tokenResult, err := removed.GetTokens()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenResult.RefreshToken).To(Equal("rt"))
+		Expect(removed.ClearTokens()).To(Succeed())
+
+		tokenResult, err = cache.GetTokens()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenResult).To(BeNil())
+	})
+})
@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/auth0/k8s-pixy-auth/auth"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +15,37 @@ var audience string
 var useIDToken bool
 var withRefreshToken bool
 var port uint16
+var flow string
+var clientSecretEnv string
+var clientSecretFile string
+var clientKeyEnv string
+var clientKeyFile string
+var clientAuthMethod string
+var clientCertFile string
+var clientCertKeyFile string
+var hubAudience string
+var assertionFile string
+var useDPoP bool
+var requirePAR bool
+var noBrowser bool
+var authorizationEndpoint string
+var tokenEndpoint string
+var jwksURI string
+var revocationEndpoint string
+var refreshMetadata bool
+var reauthenticateOn string
+var noRetry bool
+var retryTimeout time.Duration
+var refreshAhead time.Duration
+var clockSkew time.Duration
+var detectClockSkew bool
+
+const (
+	flowBrowser           = "browser"
+	flowDevice            = "device"
+	flowClientCredentials = "client-credentials"
+	flowJWTBearer         = "jwt-bearer"
+)
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&issuerEndpoint, "issuer-endpoint", "i", "", "the issuer endpoint")
@@ -24,6 +57,30 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&useIDToken, "use-id-token", false, "if the id token should be used instead of the access token")
 	rootCmd.PersistentFlags().BoolVar(&withRefreshToken, "with-refresh-token", false, "if the refresh token should be used / requested")
 	rootCmd.PersistentFlags().Uint16Var(&port, "port", 8080, "Port on which the callback from the IDP is expected.")
+	rootCmd.PersistentFlags().StringVar(&flow, "flow", flowBrowser, "the authentication flow to use: browser, device, client-credentials or jwt-bearer")
+	rootCmd.PersistentFlags().StringVar(&clientSecretEnv, "client-secret-env", "", "the environment variable to read the client secret from")
+	rootCmd.PersistentFlags().StringVar(&clientSecretFile, "client-secret-file", "", "the file to read the client secret from")
+	rootCmd.PersistentFlags().StringVar(&clientKeyEnv, "client-key-env", "", "the environment variable to read the PEM encoded client private key from for private_key_jwt")
+	rootCmd.PersistentFlags().StringVar(&clientKeyFile, "client-key-file", "", "the file to read the PEM encoded client private key from for private_key_jwt")
+	rootCmd.PersistentFlags().StringVar(&clientAuthMethod, "client-auth-method", "", "the token endpoint client authentication method to use: none, client_secret_basic, client_secret_post, client_secret_jwt or private_key_jwt. Picked from the issuer's supported methods when not set")
+	rootCmd.PersistentFlags().StringVar(&clientCertFile, "client-cert-file", "", "the PEM encoded client certificate to present for mutual TLS and certificate-bound tokens")
+	rootCmd.PersistentFlags().StringVar(&clientCertKeyFile, "client-cert-key-file", "", "the PEM encoded private key of the client certificate")
+	rootCmd.PersistentFlags().StringVar(&assertionFile, "assertion-file", "", "the file to read the JWT assertion from for the jwt-bearer flow")
+	rootCmd.PersistentFlags().StringVar(&hubAudience, "hub-audience", "", "the audience to log in to once and exchange tokens from for the audience")
+	rootCmd.PersistentFlags().BoolVar(&useDPoP, "dpop", false, "if tokens should be bound to a DPoP key pair kept in the keyring")
+	rootCmd.PersistentFlags().BoolVar(&requirePAR, "require-par", false, "if the browser flow should fail when the authorization request cannot be pushed to the issuer")
+	rootCmd.PersistentFlags().BoolVar(&noBrowser, "no-browser", false, "print the authorization URL and paste the code back instead of opening a browser. Used automatically when no display is detected")
+	rootCmd.PersistentFlags().StringVar(&authorizationEndpoint, "authorization-endpoint", "", "the authorization endpoint to use instead of discovering it from the issuer")
+	rootCmd.PersistentFlags().StringVar(&tokenEndpoint, "token-endpoint", "", "the token endpoint to use instead of discovering it from the issuer. Required when any endpoint is set manually")
+	rootCmd.PersistentFlags().StringVar(&jwksURI, "jwks-uri", "", "the JWKS url used to verify ID tokens when the endpoints are set manually")
+	rootCmd.PersistentFlags().StringVar(&revocationEndpoint, "revocation-endpoint", "", "the token revocation endpoint used by logout when the endpoints are set manually")
+	rootCmd.PersistentFlags().StringVar(&reauthenticateOn, "reauthenticate-on", string(auth.ReauthenticateOnNonTransient), "which refresh failures fall back to logging in again: invalid-grant when the refresh token was rejected, non-transient for anything but the issuer being unavailable, or any")
+	rootCmd.PersistentFlags().BoolVar(&noRetry, "no-retry", false, "send every request to the issuer only once instead of retrying when the issuer is overloaded or unavailable")
+	rootCmd.PersistentFlags().DurationVar(&retryTimeout, "retry-timeout", auth.DefaultRetryTimeout, "the total time a request to the issuer is retried for")
+	rootCmd.PersistentFlags().DurationVar(&refreshAhead, "refresh-ahead", auth.DefaultRefreshAhead, "refresh cached tokens that expire within this time instead of handing them to kubectl")
+	rootCmd.PersistentFlags().DurationVar(&clockSkew, "clock-skew", 0, "how far the local clock may be behind the issuer's clock. Tokens are treated as expiring that much earlier")
+	rootCmd.PersistentFlags().BoolVar(&detectClockSkew, "detect-clock-skew", false, "correct token expiry for the difference between the local clock and the Date header of the issuer's token responses")
+	rootCmd.PersistentFlags().BoolVar(&refreshMetadata, "refresh-metadata", false, "ignore the cached discovery and JWKS documents of the issuer and get them again")
 }
 
 var rootCmd = &cobra.Command{
@@ -0,0 +1,109 @@
+package initialization
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/auth0/k8s-pixy-auth/auth"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ClusterExtensionName is the name of the cluster extension kubectl passes to
+// exec plugins as spec.cluster.config when provideClusterInfo is set
+const ClusterExtensionName = "client.authentication.k8s.io/exec"
+
+// ClusterIssuerConfig is the issuer information kept in the cluster extension
+// so that it does not have to be copied into every user's exec args
+type ClusterIssuerConfig struct {
+	IssuerEndpoint string `json:"issuerEndpoint,omitempty"`
+	ClientID       string `json:"clientID,omitempty"`
+	Audience       string `json:"audience,omitempty"`
+}
+
+// ParseClusterIssuerConfig parses the cluster extension config
+func ParseClusterIssuerConfig(raw []byte) (ClusterIssuerConfig, error) {
+	var issuerConfig ClusterIssuerConfig
+	if err := json.Unmarshal(raw, &issuerConfig); err != nil {
+		return ClusterIssuerConfig{}, fmt.Errorf("could not parse the %s cluster extension: %s", ClusterExtensionName, err)
+	}
+
+	return issuerConfig, nil
+}
+
+// Issuer fills in the parts of <fallback> that are set in the cluster
+// extension
+func (c ClusterIssuerConfig) Issuer(fallback auth.Issuer) auth.Issuer {
+	if c.IssuerEndpoint != "" {
+		fallback.IssuerEndpoint = c.IssuerEndpoint
+	}
+
+	if c.ClientID != "" {
+		fallback.ClientID = c.ClientID
+	}
+
+	if c.Audience != "" {
+		fallback.Audience = c.Audience
+	}
+
+	return fallback
+}
+
+// setClusterIssuer writes the issuer to the extension of the cluster that the
+// context uses
+func setClusterIssuer(config *api.Config, contextName string, issuer auth.Issuer) error {
+	cluster := contextCluster(config, contextName)
+	if cluster == nil {
+		return fmt.Errorf("context %s has no cluster to provide the issuer", contextName)
+	}
+
+	raw, err := json.Marshal(ClusterIssuerConfig{
+		IssuerEndpoint: issuer.IssuerEndpoint,
+		ClientID:       issuer.ClientID,
+		Audience:       issuer.Audience,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cluster.Extensions == nil {
+		cluster.Extensions = map[string]runtime.Object{}
+	}
+	cluster.Extensions[ClusterExtensionName] = &runtime.Unknown{
+		Raw:         raw,
+		ContentType: runtime.ContentTypeJSON,
+	}
+
+	return nil
+}
+
+// getClusterIssuer reads the issuer from the extension of the cluster that
+// the context uses
+func getClusterIssuer(config *api.Config, contextName string) (ClusterIssuerConfig, bool) {
+	cluster := contextCluster(config, contextName)
+	if cluster == nil {
+		return ClusterIssuerConfig{}, false
+	}
+
+	extension, ok := cluster.Extensions[ClusterExtensionName].(*runtime.Unknown)
+	if !ok {
+		return ClusterIssuerConfig{}, false
+	}
+
+	issuerConfig, err := ParseClusterIssuerConfig(extension.Raw)
+	if err != nil {
+		return ClusterIssuerConfig{}, false
+	}
+
+	return issuerConfig, true
+}
+
+// contextCluster gets the cluster the context uses
+func contextCluster(config *api.Config, contextName string) *api.Cluster {
+	context := config.Contexts[contextName]
+	if context == nil {
+		return nil
+	}
+
+	return config.Clusters[context.Cluster]
+}
@@ -0,0 +1,30 @@
+package initialization
+
+import (
+	"github.com/auth0/k8s-pixy-auth/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClusterIssuerConfig", func() {
+	It("parses the cluster extension config", func() {
+		issuerConfig, err := ParseClusterIssuerConfig([]byte(`{"issuerEndpoint":"https://issuer","clientID":"client-id"}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issuerConfig).To(Equal(ClusterIssuerConfig{IssuerEndpoint: "https://issuer", ClientID: "client-id"}))
+	})
+
+	It("errors when the config is not valid json", func() {
+		_, err := ParseClusterIssuerConfig([]byte("<"))
+
+		Expect(err.Error()).To(Equal("could not parse the client.authentication.k8s.io/exec cluster extension: invalid character '<' looking for beginning of value"))
+	})
+
+	It("falls back to the passed in issuer for what is not set", func() {
+		issuerConfig := ClusterIssuerConfig{IssuerEndpoint: "https://issuer", Audience: "audience"}
+
+		issuer := issuerConfig.Issuer(auth.Issuer{IssuerEndpoint: "https://flag", ClientID: "flag-client", Audience: "flag-audience"})
+
+		Expect(issuer).To(Equal(auth.Issuer{IssuerEndpoint: "https://issuer", ClientID: "flag-client", Audience: "audience"}))
+	})
+})
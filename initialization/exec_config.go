@@ -0,0 +1,169 @@
+package initialization
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/auth0/k8s-pixy-auth/auth"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ExecConfig holds the issuer and options a kube config context was
+// initialized with
+type ExecConfig struct {
+	ContextName string
+	Issuer      auth.Issuer
+	Options     ExecOptions
+}
+
+// GetExecConfigs reads the issuer and options back from the exec args that
+// UpdateKubeConfig wrote for <contextName>. When <contextName> is empty the
+// ExecConfig of every context that uses k8s-pixy-auth is returned.
+func (init *Initializer) GetExecConfigs(contextName string) ([]ExecConfig, error) {
+	config, err := init.kubeConfigInteractor.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Error loading kube config: %s", err.Error())
+	}
+
+	if contextName != "" {
+		execConfig, ok := getExecConfig(config, contextName)
+		if !ok {
+			return nil, fmt.Errorf("context %s is not set up to use k8s-pixy-auth", contextName)
+		}
+
+		return []ExecConfig{execConfig}, nil
+	}
+
+	var contextNames []string
+	for name := range config.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+
+	var execConfigs []ExecConfig
+	for _, name := range contextNames {
+		if execConfig, ok := getExecConfig(config, name); ok {
+			execConfigs = append(execConfigs, execConfig)
+		}
+	}
+
+	return execConfigs, nil
+}
+
+// getExecConfig gets the ExecConfig for the context if its auth info uses
+// k8s-pixy-auth
+func getExecConfig(config *api.Config, contextName string) (ExecConfig, bool) {
+	context := config.Contexts[contextName]
+	if context == nil {
+		return ExecConfig{}, false
+	}
+
+	authInfo := config.AuthInfos[context.AuthInfo]
+	if authInfo == nil || authInfo.Exec == nil {
+		return ExecConfig{}, false
+	}
+
+	args := authInfo.Exec.Args
+	if len(args) == 0 || args[0] != "auth" {
+		return ExecConfig{}, false
+	}
+
+	issuer, options := parseExecArgs(args[1:])
+	if authInfo.Exec.ProvideClusterInfo {
+		if issuerConfig, ok := getClusterIssuer(config, contextName); ok {
+			issuer = issuerConfig.Issuer(issuer)
+			options.ProvideClusterInfo = true
+		}
+	}
+
+	if issuer.IssuerEndpoint == "" {
+		return ExecConfig{}, false
+	}
+
+	return ExecConfig{
+		ContextName: contextName,
+		Issuer:      issuer,
+		Options:     options,
+	}, true
+}
+
+// parseExecArgs is the reverse of how UpdateKubeConfig builds the exec args
+func parseExecArgs(args []string) (auth.Issuer, ExecOptions) {
+	issuer := auth.Issuer{}
+	options := ExecOptions{}
+
+	for _, arg := range args {
+		name := strings.TrimPrefix(arg, "--")
+		value := ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = name[:i], name[i+1:]
+		}
+
+		switch name {
+		case "issuer-endpoint":
+			issuer.IssuerEndpoint = value
+		case "client-id":
+			issuer.ClientID = value
+		case "audience":
+			issuer.Audience = value
+		case "port":
+			port, _ := strconv.ParseUint(value, 10, 16)
+			options.Port = uint16(port)
+		case "use-id-token":
+			options.UseIDToken = true
+		case "with-refresh-token":
+			options.WithRefreshToken = true
+		case "flow":
+			options.Flow = value
+		case "client-secret-env":
+			options.ClientSecretEnv = value
+		case "client-secret-file":
+			options.ClientSecretFile = value
+		case "client-key-env":
+			options.ClientKeyEnv = value
+		case "client-key-file":
+			options.ClientKeyFile = value
+		case "client-auth-method":
+			options.ClientAuthMethod = value
+		case "client-cert-file":
+			options.ClientCertFile = value
+		case "client-cert-key-file":
+			options.ClientCertKeyFile = value
+		case "assertion-file":
+			options.AssertionFile = value
+		case "hub-audience":
+			options.HubAudience = value
+		case "dpop":
+			options.DPoP = true
+		case "require-par":
+			options.RequirePAR = true
+		case "no-browser":
+			options.NoBrowser = true
+		case "authorization-endpoint":
+			options.AuthorizationEndpoint = value
+		case "token-endpoint":
+			options.TokenEndpoint = value
+		case "jwks-uri":
+			options.JWKSURI = value
+		case "revocation-endpoint":
+			options.RevocationEndpoint = value
+		case "reauthenticate-on":
+			options.ReauthenticateOn = value
+		case "no-retry":
+			options.NoRetry = true
+		case "retry-timeout":
+			options.RetryTimeout, _ = time.ParseDuration(value)
+		case "refresh-ahead":
+			options.RefreshAhead, _ = time.ParseDuration(value)
+		case "clock-skew":
+			options.ClockSkew, _ = time.ParseDuration(value)
+		case "detect-clock-skew":
+			options.DetectClockSkew = true
+		}
+	}
+
+	return issuer, options
+}
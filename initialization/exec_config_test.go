@@ -0,0 +1,108 @@
+package initialization
+
+import (
+	"errors"
+	"time"
+
+	"github.com/auth0/k8s-pixy-auth/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+var _ = Describe("GetExecConfigs", func() {
+	var kubeConfigInteractor mockKubeConfigInteractor
+	var i Initializer
+
+	issuer := auth.Issuer{
+		IssuerEndpoint: "https://issuer",
+		ClientID:       "clientID",
+		Audience:       "audience",
+	}
+
+	BeforeEach(func() {
+		kubeConfigInteractor = mockKubeConfigInteractor{ReturnConfig: &api.Config{}}
+		i = Initializer{kubeConfigInteractor: &kubeConfigInteractor}
+	})
+
+	It("reads back what UpdateKubeConfig wrote", func() {
+		options := ExecOptions{
+			Port:              8080,
+			WithRefreshToken:  true,
+			Flow:              "device",
+			ClientSecretEnv:   "SECRET",
+			ClientCertFile:    "cert.pem",
+			ClientCertKeyFile: "key.pem",
+			HubAudience:       "hub",
+			DPoP:              true,
+			TokenEndpoint:     "https://issuer/token",
+			JWKSURI:           "https://issuer/jwks",
+			ReauthenticateOn:  "invalid-grant",
+			NoRetry:           true,
+			RetryTimeout:      time.Minute,
+			RefreshAhead:      2 * time.Minute,
+			ClockSkew:         10 * time.Second,
+			DetectClockSkew:   true,
+		}
+		Expect(i.UpdateKubeConfig("context-name", "binary", issuer, options)).To(Succeed())
+		kubeConfigInteractor.ReturnConfig = kubeConfigInteractor.SavedConfig
+
+		execConfigs, err := i.GetExecConfigs("context-name")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execConfigs).To(Equal([]ExecConfig{{
+			ContextName: "context-name",
+			Issuer:      issuer,
+			Options:     options,
+		}}))
+	})
+
+	It("reads the issuer back from the cluster info", func() {
+		kubeConfigInteractor.ReturnConfig = &api.Config{
+			Contexts: map[string]*api.Context{"context-name": {Cluster: "cluster"}},
+			Clusters: map[string]*api.Cluster{"cluster": {Server: "https://cluster"}},
+		}
+		options := ExecOptions{Port: 8080, ProvideClusterInfo: true}
+		Expect(i.UpdateKubeConfig("context-name", "binary", issuer, options)).To(Succeed())
+		kubeConfigInteractor.ReturnConfig = kubeConfigInteractor.SavedConfig
+
+		execConfigs, err := i.GetExecConfigs("context-name")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execConfigs).To(Equal([]ExecConfig{{
+			ContextName: "context-name",
+			Issuer:      issuer,
+			Options:     options,
+		}}))
+	})
+
+	It("returns every context that uses k8s-pixy-auth", func() {
+		Expect(i.UpdateKubeConfig("b", "binary", issuer, ExecOptions{Port: 8080})).To(Succeed())
+		Expect(i.UpdateKubeConfig("a", "binary", issuer, ExecOptions{Port: 8080})).To(Succeed())
+		config := kubeConfigInteractor.SavedConfig
+		config.Contexts["other"] = &api.Context{AuthInfo: "other-user"}
+		config.AuthInfos["other-user"] = &api.AuthInfo{Token: "token"}
+		kubeConfigInteractor.ReturnConfig = config
+
+		execConfigs, err := i.GetExecConfigs("")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execConfigs).To(HaveLen(2))
+		Expect(execConfigs[0].ContextName).To(Equal("a"))
+		Expect(execConfigs[1].ContextName).To(Equal("b"))
+	})
+
+	It("errors when the context does not use k8s-pixy-auth", func() {
+		_, err := i.GetExecConfigs("missing")
+
+		Expect(err.Error()).To(Equal("context missing is not set up to use k8s-pixy-auth"))
+	})
+
+	It("errors when loading the kube config errors", func() {
+		kubeConfigInteractor.ReturnLoadError = errors.New("uh oh")
+
+		_, err := i.GetExecConfigs("context-name")
+
+		Expect(err.Error()).To(Equal("Error loading kube config: uh oh"))
+	})
+})
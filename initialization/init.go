@@ -3,6 +3,7 @@ package initialization
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/auth0/k8s-pixy-auth/auth"
 	"github.com/auth0/k8s-pixy-auth/os"
@@ -62,9 +63,89 @@ func NewDefaultInitializer() *Initializer {
 	}
 }
 
+// ExecOptions holds the options that are passed to the k8s-pixy-auth auth
+// command through the kube config exec args
+type ExecOptions struct {
+	UseIDToken       bool
+	WithRefreshToken bool
+	Port             uint16
+	// Flow is the authentication flow to use. The default browser flow is
+	// used when it is left empty.
+	Flow string
+	// ClientSecretEnv and ClientSecretFile reference where the client secret
+	// is read from. The secret itself is never written to the kube config.
+	ClientSecretEnv  string
+	ClientSecretFile string
+	// ClientKeyEnv and ClientKeyFile reference where the client private key
+	// is read from
+	ClientKeyEnv  string
+	ClientKeyFile string
+	// ClientAuthMethod forces the token endpoint client authentication method
+	ClientAuthMethod string
+	// ClientCertFile and ClientCertKeyFile reference the client certificate
+	// used for mutual TLS
+	ClientCertFile    string
+	ClientCertKeyFile string
+	// HubAudience is the audience whose token is exchanged for a token for
+	// the issuer audience
+	HubAudience string
+	// AssertionFile is the file the JWT assertion is read from
+	AssertionFile string
+	// DPoP binds the tokens to a DPoP key pair
+	DPoP bool
+	// RequirePAR makes pushing the authorization request mandatory
+	RequirePAR bool
+	// NoBrowser always uses manual code entry instead of opening a browser
+	NoBrowser bool
+	// AuthorizationEndpoint, TokenEndpoint, JWKSURI and RevocationEndpoint
+	// are used instead of discovery for issuers without a discovery document
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+	RevocationEndpoint    string
+	// ReauthenticateOn is the policy for which refresh failures fall back to
+	// logging in again. The default non-transient is used when it is empty.
+	ReauthenticateOn string
+	// NoRetry sends every request to the issuer only once
+	NoRetry bool
+	// RetryTimeout caps the total time spent retrying a request. The default
+	// is used when it is zero.
+	RetryTimeout time.Duration
+	// RefreshAhead refreshes tokens that expire within it. The default is
+	// used when it is zero.
+	RefreshAhead time.Duration
+	// ClockSkew is how far the local clock is allowed to be behind the
+	// issuer's clock
+	ClockSkew time.Duration
+	// DetectClockSkew corrects token expiry for the issuer's clock as seen in
+	// the Date header of the token response
+	DetectClockSkew bool
+	// APIVersion is the client.authentication.k8s.io version, v1beta1 or v1,
+	// of the kube config exec entry. It is not an exec arg. v1beta1 is used
+	// when it is empty.
+	APIVersion string
+	// ProvideClusterInfo writes the issuer to the cluster extension and has
+	// kubectl pass it to the plugin instead of adding it to the exec args. It
+	// is not an exec arg.
+	ProvideClusterInfo bool
+}
+
+// execAPIVersions maps the supported ExecOptions.APIVersion values to the
+// exec entry API version
+var execAPIVersions = map[string]string{
+	"":        "client.authentication.k8s.io/v1beta1",
+	"v1beta1": "client.authentication.k8s.io/v1beta1",
+	"v1":      "client.authentication.k8s.io/v1",
+}
+
 // UpdateKubeConfig updates the provided context in kube config with the
 // k8s-pixy-auth exec information
-func (init *Initializer) UpdateKubeConfig(contextName, binaryLocation string, issuer auth.Issuer, useIDToken, withRefreshToken bool, port uint16) error {
+func (init *Initializer) UpdateKubeConfig(contextName, binaryLocation string, issuer auth.Issuer, options ExecOptions) error {
+	apiVersion, ok := execAPIVersions[options.APIVersion]
+	if !ok {
+		return fmt.Errorf("unsupported exec API version %q, use v1beta1 or v1", options.APIVersion)
+	}
+
 	config, err := init.kubeConfigInteractor.LoadConfig()
 	if err != nil {
 		return fmt.Errorf("Error loading kube config: %s", err.Error())
@@ -76,27 +157,136 @@ func (init *Initializer) UpdateKubeConfig(contextName, binaryLocation string, is
 
 	authInfoName := fmt.Sprintf("%s-exec-auth", contextName)
 
-	args := []string{"auth",
-		fmt.Sprintf("--issuer-endpoint=%s", issuer.IssuerEndpoint),
-		fmt.Sprintf("--client-id=%s", issuer.ClientID),
-		fmt.Sprintf("--audience=%s", issuer.Audience),
-		fmt.Sprintf("--port=%d", port),
+	args := []string{"auth"}
+
+	if options.ProvideClusterInfo {
+		if err := setClusterIssuer(config, contextName, issuer); err != nil {
+			return err
+		}
+	} else {
+		args = append(args,
+			fmt.Sprintf("--issuer-endpoint=%s", issuer.IssuerEndpoint),
+			fmt.Sprintf("--client-id=%s", issuer.ClientID),
+			fmt.Sprintf("--audience=%s", issuer.Audience))
 	}
 
-	if useIDToken {
+	args = append(args, fmt.Sprintf("--port=%d", options.Port))
+
+	if options.UseIDToken {
 		args = append(args, "--use-id-token")
 	}
 
-	if withRefreshToken {
+	if options.WithRefreshToken {
 		args = append(args, "--with-refresh-token")
 	}
 
+	if options.Flow != "" && options.Flow != "browser" {
+		args = append(args, fmt.Sprintf("--flow=%s", options.Flow))
+	}
+
+	if options.ClientSecretEnv != "" {
+		args = append(args, fmt.Sprintf("--client-secret-env=%s", options.ClientSecretEnv))
+	}
+
+	if options.ClientSecretFile != "" {
+		args = append(args, fmt.Sprintf("--client-secret-file=%s", options.ClientSecretFile))
+	}
+
+	if options.ClientKeyEnv != "" {
+		args = append(args, fmt.Sprintf("--client-key-env=%s", options.ClientKeyEnv))
+	}
+
+	if options.ClientKeyFile != "" {
+		args = append(args, fmt.Sprintf("--client-key-file=%s", options.ClientKeyFile))
+	}
+
+	if options.ClientAuthMethod != "" {
+		args = append(args, fmt.Sprintf("--client-auth-method=%s", options.ClientAuthMethod))
+	}
+
+	if options.ClientCertFile != "" {
+		args = append(args, fmt.Sprintf("--client-cert-file=%s", options.ClientCertFile))
+	}
+
+	if options.ClientCertKeyFile != "" {
+		args = append(args, fmt.Sprintf("--client-cert-key-file=%s", options.ClientCertKeyFile))
+	}
+
+	if options.AssertionFile != "" {
+		args = append(args, fmt.Sprintf("--assertion-file=%s", options.AssertionFile))
+	}
+
+	if options.HubAudience != "" {
+		args = append(args, fmt.Sprintf("--hub-audience=%s", options.HubAudience))
+	}
+
+	if options.DPoP {
+		args = append(args, "--dpop")
+	}
+
+	if options.RequirePAR {
+		args = append(args, "--require-par")
+	}
+
+	if options.NoBrowser {
+		args = append(args, "--no-browser")
+	}
+
+	if options.AuthorizationEndpoint != "" {
+		args = append(args, fmt.Sprintf("--authorization-endpoint=%s", options.AuthorizationEndpoint))
+	}
+
+	if options.TokenEndpoint != "" {
+		args = append(args, fmt.Sprintf("--token-endpoint=%s", options.TokenEndpoint))
+	}
+
+	if options.JWKSURI != "" {
+		args = append(args, fmt.Sprintf("--jwks-uri=%s", options.JWKSURI))
+	}
+
+	if options.RevocationEndpoint != "" {
+		args = append(args, fmt.Sprintf("--revocation-endpoint=%s", options.RevocationEndpoint))
+	}
+
+	if options.ReauthenticateOn != "" && options.ReauthenticateOn != "non-transient" {
+		args = append(args, fmt.Sprintf("--reauthenticate-on=%s", options.ReauthenticateOn))
+	}
+
+	if options.NoRetry {
+		args = append(args, "--no-retry")
+	}
+
+	if options.RetryTimeout != 0 && options.RetryTimeout != auth.DefaultRetryTimeout {
+		args = append(args, fmt.Sprintf("--retry-timeout=%s", options.RetryTimeout))
+	}
+
+	if options.RefreshAhead != 0 && options.RefreshAhead != auth.DefaultRefreshAhead {
+		args = append(args, fmt.Sprintf("--refresh-ahead=%s", options.RefreshAhead))
+	}
+
+	if options.ClockSkew != 0 {
+		args = append(args, fmt.Sprintf("--clock-skew=%s", options.ClockSkew))
+	}
+
+	if options.DetectClockSkew {
+		args = append(args, "--detect-clock-skew")
+	}
+
+	execConfig := &api.ExecConfig{
+		Command:            binaryLocation,
+		Args:               args,
+		APIVersion:         apiVersion,
+		ProvideClusterInfo: options.ProvideClusterInfo,
+	}
+
+	// v1 requires the interactive mode. The browser and device flows need the
+	// user but cached and refreshed tokens can be used without them.
+	if options.APIVersion == "v1" {
+		execConfig.InteractiveMode = api.IfAvailableExecInteractiveMode
+	}
+
 	config.AuthInfos[authInfoName] = &api.AuthInfo{
-		Exec: &api.ExecConfig{
-			Command:    binaryLocation,
-			Args:       args,
-			APIVersion: "client.authentication.k8s.io/v1beta1",
-		},
+		Exec: execConfig,
 	}
 
 	associateClusterWithAuthInfo(config, contextName, authInfoName)
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/auth0/k8s-pixy-auth/auth"
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
 
@@ -89,13 +91,13 @@ var _ = Describe("init", func() {
 	})
 
 	It("loads the current kube config", func() {
-		i.UpdateKubeConfig("", "", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.LoadConfigCalled).To(BeTrue())
 	})
 
 	It("creates the context when the context does not exist", func() {
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.Contexts["context-name"].AuthInfo).To(Equal("context-name-exec-auth"))
 		Expect(kubeConfigInteractor.SavedConfig.Contexts["context-name"].Cluster).To(BeEmpty())
@@ -110,7 +112,7 @@ var _ = Describe("init", func() {
 				},
 			},
 		}
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.Contexts["context-name"].AuthInfo).To(Equal("context-name-exec-auth"))
 		Expect(kubeConfigInteractor.SavedConfig.Contexts["context-name"].Cluster).To(Equal("cluster"))
@@ -118,7 +120,7 @@ var _ = Describe("init", func() {
 
 	It("adds the issuer information as arguments", func() {
 		issuer := auth.Issuer{IssuerEndpoint: "issuer", ClientID: "client-id", Audience: "audience"}
-		i.UpdateKubeConfig("context-name", "", issuer, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "", issuer, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
 			"auth",
@@ -128,8 +130,32 @@ var _ = Describe("init", func() {
 			fmt.Sprintf("--port=%d", 8080)}))
 	})
 
+	It("provides the issuer through the cluster info instead of the arguments", func() {
+		kubeConfigInteractor.ReturnConfig = &api.Config{
+			Contexts: map[string]*api.Context{"context-name": {Cluster: "cluster"}},
+			Clusters: map[string]*api.Cluster{"cluster": {Server: "https://cluster"}},
+		}
+		issuer := auth.Issuer{IssuerEndpoint: "https://issuer", ClientID: "client-id", Audience: "audience"}
+
+		err := i.UpdateKubeConfig("context-name", "", issuer, ExecOptions{Port: 8080, ProvideClusterInfo: true})
+
+		Expect(err).NotTo(HaveOccurred())
+		execConfig := kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec
+		Expect(execConfig.Args).To(Equal([]string{"auth", "--port=8080"}))
+		Expect(execConfig.ProvideClusterInfo).To(BeTrue())
+		extension := kubeConfigInteractor.SavedConfig.Clusters["cluster"].Extensions[ClusterExtensionName].(*runtime.Unknown)
+		Expect(extension.Raw).To(MatchJSON(`{"issuerEndpoint":"https://issuer","clientID":"client-id","audience":"audience"}`))
+	})
+
+	It("errors when providing the cluster info for a context without a cluster", func() {
+		err := i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, ProvideClusterInfo: true})
+
+		Expect(err.Error()).To(Equal("context context-name has no cluster to provide the issuer"))
+		Expect(kubeConfigInteractor.SavedConfig).To(BeNil())
+	})
+
 	It("adds the use id token argument when using the id token", func() {
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, true, false, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{UseIDToken: true, Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
 			"auth",
@@ -141,7 +167,7 @@ var _ = Describe("init", func() {
 	})
 
 	It("adds the with refresh token argument when wanting to use the refresh token", func() {
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, true, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{WithRefreshToken: true, Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
 			"auth",
@@ -152,8 +178,203 @@ var _ = Describe("init", func() {
 			"--with-refresh-token"}))
 	})
 
+	It("adds the flow argument when a non-default flow is used", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, Flow: "device"})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--flow=device"}))
+	})
+
+	It("does not add the flow argument for the default browser flow", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, Flow: "browser"})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).NotTo(ContainElement("--flow=browser"))
+	})
+
+	It("adds the client secret references for the client credentials flow", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{
+			Port:             8080,
+			Flow:             "client-credentials",
+			ClientSecretEnv:  "CLIENT_SECRET",
+			ClientSecretFile: "/secrets/client",
+		})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--flow=client-credentials",
+			"--client-secret-env=CLIENT_SECRET",
+			"--client-secret-file=/secrets/client"}))
+	})
+
+	It("adds the client key and auth method arguments for confidential clients", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{
+			Port:             8080,
+			ClientKeyEnv:     "CLIENT_KEY",
+			ClientKeyFile:    "/secrets/key.pem",
+			ClientAuthMethod: "private_key_jwt",
+		})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--client-key-env=CLIENT_KEY",
+			"--client-key-file=/secrets/key.pem",
+			"--client-auth-method=private_key_jwt"}))
+	})
+
+	It("adds the client certificate arguments for mutual TLS", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{
+			Port:              8080,
+			ClientCertFile:    "/certs/client.pem",
+			ClientCertKeyFile: "/certs/client-key.pem",
+		})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--client-cert-file=/certs/client.pem",
+			"--client-cert-key-file=/certs/client-key.pem"}))
+	})
+
+	It("adds the assertion file argument for the jwt bearer flow", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{
+			Port:          8080,
+			Flow:          "jwt-bearer",
+			AssertionFile: "/var/run/secrets/tokens/token",
+		})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--flow=jwt-bearer",
+			"--assertion-file=/var/run/secrets/tokens/token"}))
+	})
+
+	It("adds the hub audience argument when exchanging tokens", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, HubAudience: "hub"})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--hub-audience=hub"}))
+	})
+
+	It("adds the dpop argument when tokens are bound to a DPoP key", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, DPoP: true})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--dpop"}))
+	})
+
+	It("adds the require par argument when pushed authorization requests are required", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, RequirePAR: true})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--require-par"}))
+	})
+
+	It("adds the no browser argument when manual code entry is used", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, NoBrowser: true})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--no-browser"}))
+	})
+
+	It("adds the endpoint arguments when the endpoints are set manually", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{
+			Port:                  8080,
+			AuthorizationEndpoint: "https://issuer/authorize",
+			TokenEndpoint:         "https://issuer/token",
+			JWKSURI:               "https://issuer/jwks",
+			RevocationEndpoint:    "https://issuer/revoke",
+		})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
+			"auth",
+			"--issuer-endpoint=",
+			"--client-id=",
+			"--audience=",
+			"--port=8080",
+			"--authorization-endpoint=https://issuer/authorize",
+			"--token-endpoint=https://issuer/token",
+			"--jwks-uri=https://issuer/jwks",
+			"--revocation-endpoint=https://issuer/revoke"}))
+	})
+
+	It("adds the reauthenticate on argument for a non-default refresh failure policy", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, ReauthenticateOn: "any"})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(ContainElement("--reauthenticate-on=any"))
+	})
+
+	It("does not add the reauthenticate on argument for the default refresh failure policy", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, ReauthenticateOn: "non-transient"})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).NotTo(ContainElement(HavePrefix("--reauthenticate-on")))
+	})
+
+	It("adds the retry arguments when they are not the defaults", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, NoRetry: true, RetryTimeout: 2 * time.Minute})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(ContainElements("--no-retry", "--retry-timeout=2m0s"))
+	})
+
+	It("does not add the retry timeout argument for the default timeout", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, RetryTimeout: auth.DefaultRetryTimeout})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).NotTo(ContainElement(HavePrefix("--retry-timeout")))
+	})
+
+	It("adds the token expiry arguments when they are not the defaults", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, RefreshAhead: 5 * time.Minute, ClockSkew: 30 * time.Second, DetectClockSkew: true})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(ContainElements("--refresh-ahead=5m0s", "--clock-skew=30s", "--detect-clock-skew"))
+	})
+
+	It("does not add the refresh ahead argument for the default window", func() {
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, RefreshAhead: auth.DefaultRefreshAhead})
+
+		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).NotTo(ContainElement(HavePrefix("--refresh-ahead")))
+	})
+
 	It("adds a non-default callback port to the kubeconfig", func() {
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, false, 1337)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 1337})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Args).To(Equal([]string{
 			"auth",
@@ -164,17 +385,33 @@ var _ = Describe("init", func() {
 	})
 
 	It("adds the binary location", func() {
-		i.UpdateKubeConfig("context-name", "binary-location", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "binary-location", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.Command).To(Equal("binary-location"))
 	})
 
 	It("adds the correct API version", func() {
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec.APIVersion).To(Equal("client.authentication.k8s.io/v1beta1"))
 	})
 
+	It("writes a v1 entry that uses stdin if available", func() {
+		err := i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, APIVersion: "v1"})
+
+		Expect(err).NotTo(HaveOccurred())
+		execConfig := kubeConfigInteractor.SavedConfig.AuthInfos["context-name-exec-auth"].Exec
+		Expect(execConfig.APIVersion).To(Equal("client.authentication.k8s.io/v1"))
+		Expect(execConfig.InteractiveMode).To(Equal(api.IfAvailableExecInteractiveMode))
+	})
+
+	It("errors for unsupported API versions", func() {
+		err := i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080, APIVersion: "v1alpha1"})
+
+		Expect(err.Error()).To(Equal(`unsupported exec API version "v1alpha1", use v1beta1 or v1`))
+		Expect(kubeConfigInteractor.SavedConfig).To(BeNil())
+	})
+
 	It("keeps existing auth info", func() {
 		contextAuth := &api.AuthInfo{
 			ClientCertificate: "i am cert",
@@ -186,21 +423,21 @@ var _ = Describe("init", func() {
 			},
 		}
 
-		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, false, false, 8080)
+		i.UpdateKubeConfig("context-name", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(kubeConfigInteractor.SavedConfig.AuthInfos["context-name"]).To(Equal(contextAuth))
 	})
 
 	It("returns any errors from loading a config", func() {
 		kubeConfigInteractor.ReturnLoadError = errors.New("someerror")
-		err := i.UpdateKubeConfig("", "", auth.Issuer{}, false, false, 8080)
+		err := i.UpdateKubeConfig("", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(err.Error()).To(Equal("Error loading kube config: someerror"))
 	})
 
 	It("returns any errors from saving a config", func() {
 		kubeConfigInteractor.ReturnSaveError = errors.New("someerror")
-		err := i.UpdateKubeConfig("", "", auth.Issuer{}, false, false, 8080)
+		err := i.UpdateKubeConfig("", "", auth.Issuer{}, ExecOptions{Port: 8080})
 
 		Expect(err.Error()).To(Equal("Error saving kube config: someerror"))
 	})
@@ -104,6 +104,24 @@ func (i DefaultInteractor) CopyFile(source, destination string) error {
 	return destFile.Close()
 }
 
+// HasDisplay returns whether a browser can be opened on the machine. On Linux
+// this requires an X11 or Wayland display while on macOS it is assumed there
+// is none when connected over SSH.
+func (i DefaultInteractor) HasDisplay() bool {
+	switch runtime.GOOS {
+	case "windows":
+		return true
+	case "darwin":
+		return os.Getenv("SSH_CONNECTION") == "" && os.Getenv("SSH_TTY") == ""
+	}
+
+	if isWSL, err := i.IsWSL(); err == nil && isWSL {
+		return true
+	}
+
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
 // IsWSL runs the "uname -a" command to see if the output contains "microsoft" and returns true if it does, else false.
 // This is so we can check if the Linux OS is actually Windows SubSystem for Linux, which requires
 // A different command to open a browser.